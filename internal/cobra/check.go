@@ -0,0 +1,95 @@
+package cobra
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check that the project layout is up to date with its configuration",
+	Long: `Check that the project layout is up to date with its configuration.
+
+It runs the generation without modifying anything and exits with an error (listing concerned files)
+when any generated file would be created, updated or removed by "craft generate", when a generated file was modified manually
+or when .craft file would be rewritten.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		destdir, _ := os.Getwd()
+
+		var config craft.Configuration
+		if err := craft.Read(destdir, &config); err != nil {
+			fatal(ctx, fmt.Errorf("read %s: %w", craft.File, err))
+		}
+		config.EnsureDefaults()
+
+		// validate craft struct
+		if err := validator.New().Struct(config); err != nil {
+			fatal(ctx, err)
+		}
+
+		// run generation without modifying anything
+		options, err := generateOptions(ctx, destdir, config)
+		if err != nil {
+			fatal(ctx, err)
+		}
+		config, report, err := generate.Run(ctx, config, append(options, generate.WithDryRun())...)
+		if err != nil {
+			fatal(ctx, err)
+		}
+
+		drift := printDrift(cmd.OutOrStdout(), destdir, report.Changes)
+
+		// verify craft configuration wouldn't be rewritten
+		expected, err := craft.Encode(config)
+		if err != nil {
+			fatal(ctx, err)
+		}
+		actual, _ := os.ReadFile(filepath.Join(destdir, craft.File)) // file was already read before
+		if !bytes.Equal(expected, actual) {
+			fmt.Fprintf(cmd.OutOrStdout(), "%-10s %s\n", generate.ActionUpdate, craft.File)
+			drift = true
+		}
+
+		if drift {
+			fatal(ctx, errors.New(`project layout isn't up to date, run "craft generate" to update it`))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().BoolVar(&offline, "offline", false, offlineUsage)
+	checkCmd.Flags().StringArrayVar(&templates, "templates", nil, templatesUsage)
+}
+
+// printDrift writes in out the changes that would modify destdir (or would have modified it without manual edits)
+// and returns whether there's at least one.
+func printDrift(out io.Writer, destdir string, changes []generate.Change) bool {
+	var drift bool
+	for _, change := range changes {
+		switch change.Action {
+		case generate.ActionCreate, generate.ActionRemove, generate.ActionUpdate:
+		case generate.ActionSkip:
+			if change.Reason != generate.ReasonModified {
+				continue
+			}
+		case generate.ActionUnchanged:
+			continue
+		}
+		fmt.Fprintf(out, "%-10s %s\n", change.Action, relative(destdir, change.Dest))
+		drift = true
+	}
+	return drift
+}
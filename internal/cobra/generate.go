@@ -1,57 +1,198 @@
 package cobra
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
 	"github.com/spf13/cobra"
 
 	"github.com/kilianpaquier/craft/pkg/craft"
 	"github.com/kilianpaquier/craft/pkg/generate"
 	"github.com/kilianpaquier/craft/pkg/generate/handler"
 	"github.com/kilianpaquier/craft/pkg/generate/parser"
+	"github.com/kilianpaquier/craft/pkg/generate/plugin"
 	"github.com/kilianpaquier/craft/pkg/initialize"
+	"github.com/kilianpaquier/craft/pkg/source"
 )
 
-var generateCmd = &cobra.Command{
-	Use:   "generate",
-	Short: "Generate the project layout",
-	Run: func(cmd *cobra.Command, _ []string) {
-		ctx := cmd.Context()
-		destdir, _ := os.Getwd()
+var (
+	diff      bool
+	dryRun    bool
+	offline   bool
+	report    string
+	templates []string
 
-		config, err := initialize.Run(ctx, destdir)
-		if err != nil && !errors.Is(err, initialize.ErrAlreadyInitialized) {
-			fatal(ctx, err)
-		}
-		config.EnsureDefaults()
+	generateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Generate the project layout",
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			destdir, _ := os.Getwd()
 
-		// validate craft struct
-		if err := validator.New().Struct(config); err != nil {
-			fatal(ctx, err)
-		}
+			if report != "" && report != "json" {
+				fatal(ctx, errors.New(`invalid --report argument, must be "json"`))
+			}
+
+			config, err := initialize.Run(ctx, destdir)
+			if err != nil && !errors.Is(err, initialize.ErrAlreadyInitialized) {
+				fatal(ctx, err)
+			}
+			config.EnsureDefaults()
+
+			// validate craft struct
+			if err := validator.New().Struct(config); err != nil {
+				fatal(ctx, err)
+			}
+
+			// run generation
+			options, err := generateOptions(ctx, destdir, config)
+			if err != nil {
+				fatal(ctx, err)
+			}
+			if dryRun || diff {
+				options = append(options, generate.WithDryRun())
+			}
+			config, result, err := generate.Run(ctx, config, options...)
+			if report != "" {
+				if err := printReport(cmd.OutOrStdout(), destdir, result); err != nil {
+					fatal(ctx, err)
+				}
+			}
+			if err != nil {
+				fatal(ctx, err)
+			}
+
+			if diff {
+				printDiff(cmd.OutOrStdout(), destdir, result.Changes)
+				return
+			}
+			if dryRun {
+				if report == "" {
+					printPlan(cmd.OutOrStdout(), destdir, result.Changes)
+				}
+				return
+			}
+
+			// save craft configuration
+			if err := craft.Write(destdir, config); err != nil {
+				fatal(ctx, err)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().BoolVar(&diff, "diff", false, "show the unified diff of what would be generated, updated or removed without modifying anything")
+	generateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be generated, updated or removed without modifying anything")
+	generateCmd.Flags().StringVar(&report, "report", "", `show the generation report (action, reason, handler and duration of each file and parser having set each metadata field) in given format (only "json" is supported)`)
+	generateCmd.MarkFlagsMutuallyExclusive("diff", "report")
+	generateCmd.Flags().BoolVar(&offline, "offline", false, offlineUsage)
+	generateCmd.Flags().StringArrayVar(&templates, "templates", nil, templatesUsage)
+}
 
-		// run generation
-		options := []generate.RunOption{
-			generate.WithDestination(destdir),
-			generate.WithHandlers(handler.Defaults()...),
-			generate.WithLogger(log),
-			generate.WithParsers(parser.Defaults()...),
-			generate.WithTemplates("_templates", generate.FS()),
+// offlineUsage is the usage of --offline flag shared by all commands running the generation.
+const offlineUsage = "only use the cached templates source configured in " + craft.File + " (it must have been fetched at least once)"
+
+// templatesUsage is the usage of --templates flag shared by all commands running the generation.
+const templatesUsage = "directory of templates overriding or adding craft ones (can be given multiple times, last ones take precedence, project's " + craft.TemplatesDir + " directory is always used last)"
+
+// generateOptions returns the generate.Run options shared by all commands running the generation in destdir.
+//
+// Plugins are the ones found in PATH and the ones configured in config (see plugin.Discover).
+// Templates are craft embedded ones or the templates source configured in config (fetched with source.Fetch).
+// Templates layers are the ones given with --templates followed by the project's craft.TemplatesDir.
+func generateOptions(ctx context.Context, destdir string, config craft.Configuration) ([]generate.RunOption, error) {
+	layers := make([]cfs.FS, 0, len(templates)+1)
+	for _, dir := range append(slices.Clone(templates), filepath.Join(destdir, craft.TemplatesDir)) {
+		abs, _ := filepath.Abs(dir)
+		layers = append(layers, generate.TemplateLayer(abs, cfs.OS()))
+	}
+
+	tmpl := generate.WithTemplates("_templates", generate.FS())
+	if config.Templates != nil {
+		opts := []source.FetchOption{source.WithLogger(log)}
+		if offline {
+			opts = append(opts, source.WithOffline())
 		}
-		config, err = generate.Run(ctx, config, options...)
+		dir, err := source.Fetch(ctx, *config.Templates, opts...)
 		if err != nil {
-			fatal(ctx, err)
+			return nil, fmt.Errorf("templates source: %w", err)
 		}
+		tmpl = generate.WithTemplates(".", generate.TemplateLayer(dir, cfs.OS()))
+	}
+
+	plugins, err := plugin.Discover(ctx, log, config.Plugins...)
+	if err != nil {
+		return nil, fmt.Errorf("plugins: %w", err)
+	}
 
-		// save craft configuration
-		if err := craft.Write(destdir, config); err != nil {
-			fatal(ctx, err)
+	return []generate.RunOption{
+		generate.WithDestination(destdir),
+		generate.WithHandlers(handler.Defaults(plugin.Handlers(ctx, plugins...)...)...),
+		generate.WithLock(),
+		generate.WithLogger(log),
+		generate.WithParserNodes(parser.Defaults(plugin.Parsers(plugins...)...)...),
+		generate.WithTemplateLayers(layers...),
+		tmpl,
+		generate.WithVersion(version),
+		generate.WithWorkers(runtime.GOMAXPROCS(0)),
+	}, nil
+}
+
+// printPlan writes in out the action of each input change with its path relative to destdir.
+func printPlan(out io.Writer, destdir string, changes []generate.Change) {
+	for _, change := range changes {
+		fmt.Fprintf(out, "%-10s %s\n", change.Action, relative(destdir, change.Dest))
+	}
+}
+
+// printDiff writes in out the unified diff of each input change with its path relative to destdir.
+//
+// Skipped changes are written as comments with the reason they are skipped.
+func printDiff(out io.Writer, destdir string, changes []generate.Change) {
+	for _, change := range changes {
+		name := relative(destdir, change.Dest)
+		if change.Action == generate.ActionSkip {
+			fmt.Fprintf(out, "# skipping %s: %s\n", name, change.Reason)
+			continue
 		}
-	},
+		fmt.Fprint(out, change.Diff(name))
+	}
 }
 
-func init() {
-	rootCmd.AddCommand(generateCmd)
+// printReport writes in out the input report as JSON with its changes destination relative to destdir.
+func printReport(out io.Writer, destdir string, report generate.Report) error {
+	changes := make([]generate.Change, 0, len(report.Changes))
+	for _, change := range report.Changes {
+		change.Dest = relative(destdir, change.Dest)
+		changes = append(changes, change)
+	}
+	report.Changes = changes
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	return nil
+}
+
+// relative returns dest relative to destdir or dest itself in case it's not possible.
+func relative(destdir, dest string) string {
+	rel, err := filepath.Rel(destdir, dest)
+	if err != nil {
+		return dest
+	}
+	return filepath.ToSlash(rel)
 }
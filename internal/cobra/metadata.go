@@ -0,0 +1,147 @@
+package cobra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+var (
+	format string
+
+	metadataCmd = &cobra.Command{
+		Use:   "metadata",
+		Short: "Show the metadata given to templates",
+		Long: `Show the metadata given to templates.
+
+It runs all parsers without modifying anything and prints the resulting metadata (with templates properties names, e.g. .ProjectName)
+along with the parser having set each property.`,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			destdir, _ := os.Getwd()
+
+			if format != "json" && format != "yaml" {
+				fatal(ctx, errors.New(`invalid --format argument, must be either "json" or "yaml"`))
+			}
+
+			var config craft.Configuration
+			if err := craft.Read(destdir, &config); err != nil {
+				fatal(ctx, fmt.Errorf("read %s: %w", craft.File, err))
+			}
+			config.EnsureDefaults()
+
+			// validate craft struct
+			if err := validator.New().Struct(config); err != nil {
+				fatal(ctx, err)
+			}
+
+			options, err := generateOptions(ctx, destdir, config)
+			if err != nil {
+				fatal(ctx, err)
+			}
+			metadata, provenance, err := generate.Parse(ctx, config, options...)
+			if err != nil {
+				fatal(ctx, err)
+			}
+
+			if err := printMetadata(cmd.OutOrStdout(), format, metadata, provenance); err != nil {
+				fatal(ctx, err)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(metadataCmd)
+
+	metadataCmd.Flags().StringVar(&format, "format", "yaml", `metadata output format (either "json" or "yaml")`)
+	metadataCmd.Flags().BoolVar(&offline, "offline", false, offlineUsage)
+	metadataCmd.Flags().StringArrayVar(&templates, "templates", nil, templatesUsage)
+}
+
+// metadataDocument is the document printed by metadata command.
+//
+// Metadata is keyed with templates properties names (see templateData).
+type metadataDocument struct {
+	Metadata   any               `json:"metadata"             yaml:"metadata"`
+	Provenance map[string]string `json:"provenance,omitempty" yaml:"provenance,omitempty"`
+}
+
+// printMetadata writes in out the input metadata and provenance in given format (either "json" or "yaml").
+func printMetadata(out io.Writer, format string, metadata generate.Metadata, provenance map[string]string) error {
+	document := metadataDocument{Metadata: templateData(reflect.ValueOf(metadata)), Provenance: provenance}
+
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(document); err != nil {
+			return fmt.Errorf("encode metadata: %w", err)
+		}
+		return nil
+	}
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("encode metadata: %w", err)
+	}
+	return encoder.Close() //nolint:wrapcheck
+}
+
+// templateData returns value as seen by templates, i.e. structs as maps keyed by their exported fields names
+// (embedded structs fields being promoted), pointers dereferenced and maps keys as strings.
+//
+// It's used instead of json or yaml tags since they don't match templates properties names.
+func templateData(value reflect.Value) any {
+	switch value.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			return nil
+		}
+		return templateData(value.Elem())
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		data := make(map[string]any, value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			data[fmt.Sprint(iter.Key().Interface())] = templateData(iter.Value())
+		}
+		return data
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		data := make([]any, 0, value.Len())
+		for i := range value.Len() {
+			data = append(data, templateData(value.Index(i)))
+		}
+		return data
+	case reflect.Struct:
+		data := map[string]any{}
+		for _, field := range reflect.VisibleFields(value.Type()) {
+			if field.Anonymous || !field.IsExported() {
+				continue
+			}
+			if fieldValue, err := value.FieldByIndexErr(field.Index); err == nil { // nil embedded struct pointer otherwise
+				data[field.Name] = templateData(fieldValue)
+			}
+		}
+		return data
+	default:
+		if !value.IsValid() {
+			return nil
+		}
+		return value.Interface()
+	}
+}
@@ -0,0 +1,114 @@
+package cobra
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+var (
+	metadataFile string
+
+	renderCmd = &cobra.Command{
+		Use:   "render <template>",
+		Short: "Render a single template",
+		Long: `Render a single template and print the result.
+
+The template path is relative to the templates directory, with or without .tmpl extension (e.g. ".github/workflows/ci.yml.tmpl").
+It's rendered with the metadata given to templates during generation (see "craft metadata")
+or with the one given with --metadata (in "craft metadata" output format) without running any parser.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+			destdir, _ := os.Getwd()
+
+			var config craft.Configuration
+			if err := craft.Read(destdir, &config); err != nil && (metadataFile == "" || !errors.Is(err, os.ErrNotExist)) {
+				fatal(ctx, fmt.Errorf("read %s: %w", craft.File, err))
+			}
+			config.EnsureDefaults()
+
+			// validate craft struct
+			if err := validator.New().Struct(config); err != nil && metadataFile == "" {
+				fatal(ctx, err)
+			}
+
+			options, err := generateOptions(ctx, destdir, config)
+			if err != nil {
+				fatal(ctx, err)
+			}
+
+			var metadata generate.Metadata
+			if metadataFile != "" {
+				metadata, err = readMetadata(metadataFile, config)
+			} else {
+				metadata, _, err = generate.Parse(ctx, config, options...)
+			}
+			if err != nil {
+				fatal(ctx, err)
+			}
+
+			content, err := generate.Render(ctx, metadata, args[0], options...)
+			if err != nil {
+				fatal(ctx, err)
+			}
+			_, _ = cmd.OutOrStdout().Write(content)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringVar(&metadataFile, "metadata", "", `JSON or YAML file with the metadata to render the template with (e.g. "craft metadata" output), no parser is run when given`)
+	renderCmd.Flags().BoolVar(&offline, "offline", false, offlineUsage)
+	renderCmd.Flags().StringArrayVar(&templates, "templates", nil, templatesUsage)
+}
+
+// readMetadata reads the metadata document (see metadataDocument) at src
+// and decodes its metadata on top of config (keys being templates properties names).
+func readMetadata(src string, config craft.Configuration) (generate.Metadata, error) {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return generate.Metadata{}, fmt.Errorf("read metadata: %w", err)
+	}
+
+	var document metadataDocument
+	if err := yaml.Unmarshal(content, &document); err != nil { // YAML is a superset of JSON
+		return generate.Metadata{}, fmt.Errorf("unmarshal metadata: %w", err)
+	}
+
+	metadata := generate.Metadata{Configuration: config}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			emptyStructHook,
+		),
+		Result: &metadata,
+		Squash: true,
+	})
+	if err != nil {
+		return generate.Metadata{}, fmt.Errorf("metadata decoder: %w", err)
+	}
+	if err := decoder.Decode(document.Metadata); err != nil {
+		return generate.Metadata{}, fmt.Errorf("decode metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// emptyStructHook decodes any value into an empty struct (e.g. Clis, Crons, Jobs and Workers values written as null).
+func emptyStructHook(_ reflect.Type, to reflect.Type, data any) (any, error) {
+	if to == reflect.TypeOf(struct{}{}) {
+		return struct{}{}, nil
+	}
+	return data, nil
+}
@@ -3,6 +3,9 @@ package cobra
 import (
 	"context"
 	"errors"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -31,9 +34,14 @@ func init() {
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Interruption signals (e.g. Ctrl-C) cancel the command context to stop it cleanly.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fatal(context.Background(), err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		fatal(ctx, err)
 	}
 }
 
@@ -1,5 +1,7 @@
 package craft
 
+import "path"
+
 // Configuration represents all options configurable in .craft file at root project.
 //
 // Note that yaml tags are for .craft file property keys and json tags for templating data.
@@ -8,6 +10,9 @@ type Configuration struct {
 	CI           *CI           `json:"-"                     yaml:"ci,omitempty"                             validate:"omitempty,required"`
 	Description  *string       `json:"description,omitempty" yaml:"description,omitempty"`
 	Docker       *Docker       `json:"docker,omitempty"      yaml:"docker,omitempty"                         validate:"omitempty,required"`
+	Exclude      []string      `json:"-"                     yaml:"exclude,omitempty"       builder:"append"`
+	Force        []string      `json:"-"                     yaml:"force,omitempty"         builder:"append"`
+	Hooks        *Hooks        `json:"-"                     yaml:"hooks,omitempty"                          validate:"omitempty,required"`
 	License      *string       `json:"-"                     yaml:"license,omitempty"                        validate:"omitempty,oneof=agpl-3.0 apache-2.0 bsd-2-clause bsd-3-clause bsl-1.0 cc0-1.0 epl-2.0 gpl-2.0 gpl-3.0 lgpl-2.1 mit mpl-2.0 unlicense"`
 	Maintainers  []*Maintainer `json:"maintainers,omitempty" yaml:"maintainers,omitempty"   builder:"append" validate:"required,dive,required"`
 	NoChart      bool          `json:"-"                     yaml:"no_chart,omitempty"`
@@ -15,6 +20,8 @@ type Configuration struct {
 	NoMakefile   bool          `json:"-"                     yaml:"no_makefile,omitempty"`
 	NoReadme     bool          `json:"-"                     yaml:"no_readme,omitempty"`
 	Platform     string        `json:"-"                     yaml:"platform,omitempty"                       validate:"omitempty,oneof=bitbucket gitea github gitlab"`
+	Plugins      []string      `json:"-"                     yaml:"plugins,omitempty"       builder:"append"`
+	Templates    *Templates    `json:"-"                     yaml:"templates,omitempty"                      validate:"omitempty,required"`
 }
 
 // Auth contains all authentication methods related to CI configuration.
@@ -59,6 +66,19 @@ type Static struct {
 	Name string `json:"-" yaml:"name,omitempty" validate:"required,oneof=netlify pages"`
 }
 
+// Templates is the struct for craft external templates source.
+//
+// Source is either a git repository (URL or local path) checked out at Ref (default branch if empty)
+// or a tarball (.tar, .tar.gz or .tgz) path or HTTP(S) URL.
+// Dir is the templates directory inside the source (its root if empty)
+// and Checksum pins the fetched source content (see source.Fetch).
+type Templates struct {
+	Checksum string `json:"-" yaml:"checksum,omitempty" validate:"omitempty,startswith=sha256:"`
+	Dir      string `json:"-" yaml:"dir,omitempty"`
+	Ref      string `json:"-" yaml:"ref,omitempty"`
+	Source   string `json:"-" yaml:"source,omitempty"   validate:"required"`
+}
+
 // IsBot returns truthy in case the input bot is the one specified in configuration.
 //
 // It returns false if no maintenance bot is specified in configuration.
@@ -73,6 +93,30 @@ func (c Configuration) IsCI(name string) bool {
 	return c.CI != nil && c.CI.Name == name
 }
 
+// IsExcluded returns truthy in case the input path (slash separated and relative to project root)
+// or one of its parent directories matches one of Exclude globs (see path.Match for globs syntax).
+func (c Configuration) IsExcluded(name string) bool {
+	return matchAny(c.Exclude, name)
+}
+
+// IsForced returns truthy in case the input path (slash separated and relative to project root)
+// or one of its parent directories matches one of Force globs (see path.Match for globs syntax).
+func (c Configuration) IsForced(name string) bool {
+	return matchAny(c.Force, name)
+}
+
+// matchAny returns truthy in case name or one of its parent directories matches one of input globs.
+func matchAny(globs []string, name string) bool {
+	for current := name; current != "." && current != "/"; current = path.Dir(current) {
+		for _, glob := range globs {
+			if ok, _ := path.Match(glob, current); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // HasDockerRegistry returns truthy in case the configuration has a docker registry configuration.
 func (c Configuration) HasDockerRegistry() bool {
 	return c.Docker != nil && c.Docker.Registry != nil
@@ -4,6 +4,21 @@ const (
 	// File is the craft configuration file name.
 	File = ".craft"
 
+	// LockFile is the craft generation lock file name.
+	//
+	// It keeps track of all generated files alongside their template, craft version and checksum.
+	LockFile = ".craft.lock"
+
+	// TemplatesDir is the project directory where templates can be overridden or added
+	// on top of craft ones (it can't be inside .craft since it's a file).
+	TemplatesDir = ".craft-templates"
+
+	// ManifestFile is the declarative handlers file name at the root of a templates directory.
+	//
+	// It maps templates files to their delimiters, parts and generation or removal conditions
+	// and as such avoids writing Go handlers for a whole templates set.
+	ManifestFile = "craft.yaml"
+
 	// TmplExtension is the extension for templates file.
 	TmplExtension = ".tmpl"
 
@@ -13,9 +28,11 @@ const (
 	// and as such files with only templates parts (define) can be created.
 	PartExtension = ".part"
 
-	// PatchExtension is the extension for templates files patches.
+	// PatchExtension is the extension for generated files patches.
 	//
-	// It will be used in the future to patch altered files by users to follow updates with less generation issues.
+	// A patch is a user owned unified diff file (e.g. .github/workflows/ci.yml.patch)
+	// placed next to the generated file it patches. It's applied on top of the rendered template at every generation,
+	// allowing users to keep local edits while still following templates updates.
 	PatchExtension = ".patch"
 
 	// Gocmd represents the cmd folder where go main.go should be placed according to go layout.
@@ -79,5 +96,12 @@ const (
 	Mendio string = "mend.io"
 )
 
+const (
+	// HookFail is the hook policy on error failing the generation.
+	HookFail string = "fail"
+	// HookWarn is the hook policy on error only logging a warning.
+	HookWarn string = "warn"
+)
+
 // SemanticRelease is the value for github / gitlab release with semantic-release.
 const SemanticRelease string = "semantic-release"
@@ -29,6 +29,19 @@ func Read(srcdir string, out any) error {
 func Write(destdir string, config Configuration) error {
 	dest := filepath.Join(destdir, File)
 
+	content, err := Encode(config)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(dest, content, cfs.RwRR); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	return nil
+}
+
+// Encode returns the .craft file content of the input configuration (as written by Write).
+func Encode(config Configuration) ([]byte, error) {
 	// create a buffer with craft notice
 	buffer := bytes.NewBufferString("# Craft configuration file (https://github.com/kilianpaquier/craft)\n---\n")
 
@@ -38,11 +51,7 @@ func Write(destdir string, config Configuration) error {
 	defer encoder.Close()
 	encoder.SetIndent(2)
 	if err := encoder.Encode(config); err != nil {
-		return fmt.Errorf("encode file: %w", err)
-	}
-
-	if err := os.WriteFile(dest, buffer.Bytes(), cfs.RwRR); err != nil {
-		return fmt.Errorf("write file: %w", err)
+		return nil, fmt.Errorf("encode file: %w", err)
 	}
-	return nil
+	return buffer.Bytes(), nil
 }
@@ -110,3 +110,22 @@ func TestWriteCraft(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+func TestEncode(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		config := craft.Configuration{
+			Maintainers: []*craft.Maintainer{{Name: "maintainer name"}},
+			NoChart:     true,
+		}
+		expected := "# Craft configuration file (https://github.com/kilianpaquier/craft)\n---\n" +
+			"maintainers:\n  - name: maintainer name\nno_chart: true\n"
+
+		// Act
+		content, err := craft.Encode(config)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	})
+}
@@ -0,0 +1,24 @@
+package craft
+
+import "time"
+
+// Hooks is the struct for craft commands run before parsers (pre) and after all handlers (post) during generation.
+type Hooks struct {
+	Post []Hook `json:"-" yaml:"post,omitempty" builder:"append" validate:"dive"`
+	Pre  []Hook `json:"-" yaml:"pre,omitempty"  builder:"append" validate:"dive"`
+}
+
+// Hook is a command (run with sh -c) with its working directory (relative to project root),
+// additional environment variables, timeout (none if zero) and policy on error (fail by default).
+type Hook struct {
+	Command string            `json:"-" yaml:"command,omitempty"  validate:"required"`
+	Dir     string            `json:"-" yaml:"dir,omitempty"`
+	Env     map[string]string `json:"-" yaml:"env,omitempty"`
+	OnError string            `json:"-" yaml:"on_error,omitempty" validate:"omitempty,oneof=fail warn"`
+	Timeout time.Duration     `json:"-" yaml:"timeout,omitempty"`
+}
+
+// IsWarnOnError returns truthy in case the hook failure must only be logged as a warning instead of failing the generation.
+func (h Hook) IsWarnOnError() bool {
+	return h.OnError == HookWarn
+}
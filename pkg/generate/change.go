@@ -0,0 +1,450 @@
+package generate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Action represents what Run does (or would do with WithDryRun) on a destination file.
+type Action string
+
+const (
+	// ActionCreate is the action when the destination file doesn't exist yet.
+	ActionCreate Action = "create"
+
+	// ActionUpdate is the action when the destination file exists but its content (or executable rights) differ.
+	ActionUpdate Action = "update"
+
+	// ActionUnchanged is the action when the destination file is already up to date.
+	ActionUnchanged Action = "unchanged"
+
+	// ActionRemove is the action when the destination file exists and must be removed.
+	ActionRemove Action = "remove"
+
+	// ActionSkip is the action when the destination file isn't generated because its handler said so,
+	// for instance when the file exists and isn't a generated one anymore (user owned).
+	ActionSkip Action = "skip"
+)
+
+// Change represents a modification done (or to be done with WithDryRun) by Run on a destination file.
+type Change struct {
+	// Action is the action taken on Dest.
+	Action Action `json:"action"`
+
+	// Content is the rendered content of Dest.
+	//
+	// It's empty when Action is either ActionRemove or ActionSkip.
+	Content []byte `json:"-"`
+
+	// Dest is the destination file path.
+	Dest string `json:"dest"`
+
+	// Duration is the time spent to compute the change (finding its handler, rendering its template, etc.).
+	//
+	// It's only set for changes coming from a template.
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Handler is the name of the handler (e.g. "handler.Makefile") which handled Src.
+	//
+	// It's empty when the change doesn't come from a template.
+	Handler string `json:"handler,omitempty"`
+
+	// Mode is the file mode given to Dest when written.
+	//
+	// When it's a symbolic link (fs.ModeSymlink), Content is the link target.
+	Mode fs.FileMode `json:"-"`
+
+	// Previous is the content of Dest before the change.
+	//
+	// It's empty when Dest doesn't exist (or isn't a regular file) or when Action is ActionSkip.
+	Previous []byte `json:"-"`
+
+	// Reason explains why the change is done (or not done with ActionSkip).
+	//
+	// It may be empty when the action speaks for itself.
+	Reason string `json:"reason,omitempty"`
+
+	// Src is the template file path from which Dest is generated.
+	//
+	// It's empty when the change doesn't come from a template (e.g. a parser writing a file).
+	Src string `json:"src,omitempty"`
+}
+
+// Report represents the outcome of Run.
+type Report struct {
+	// Changes is the slice of changes done on destination directory (or to be done when WithDryRun is given).
+	Changes []Change `json:"changes"`
+
+	// Duration is the total time spent in Run.
+	Duration time.Duration `json:"duration"`
+
+	// Provenance is the map of Metadata fields set by parsers (see ParserNode for fields names)
+	// with the name of the parser having set them last.
+	Provenance map[string]string `json:"provenance,omitempty"`
+}
+
+// noEOL is the unified diff marker for a file not ending with a new line.
+const noEOL = `\ No newline at end of file`
+
+// Diff returns the unified diff (git style) between Previous and Content,
+// name being used as file name (usually Dest relative path to destination directory).
+//
+// It returns an empty string when there's nothing to show,
+// i.e. when Action is either ActionUnchanged or ActionSkip.
+func (c Change) Diff(name string) string {
+	diff := difflib.UnifiedDiff{
+		A:        lines(c.Previous),
+		B:        lines(c.Content),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	}
+
+	switch c.Action {
+	case ActionCreate:
+		diff.FromFile = "/dev/null"
+	case ActionRemove:
+		diff.B = nil
+		diff.ToFile = "/dev/null"
+	case ActionUpdate:
+	default:
+		return ""
+	}
+
+	result, _ := difflib.GetUnifiedDiffString(diff) // error can only come from writer and strings.Builder doesn't return any
+	return result
+}
+
+// lines splits the input content into lines (with their line ending).
+//
+// In case the last line doesn't end with a line ending,
+// the unified diff marker "\ No newline at end of file" is added to it.
+func lines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	result := strings.SplitAfter(string(content), "\n")
+	last := len(result) - 1
+	if result[last] == "" {
+		return result[:last]
+	}
+	result[last] += "\n" + noEOL + "\n"
+	return result
+}
+
+// WriteFile writes content into dest with the given perm (even when dest already exists)
+// and records the associated Change into Run returned changes.
+//
+// When Run is executed with WithDryRun, nothing is written, the Change is only recorded.
+//
+// It should be used by parsers in place of os.WriteFile.
+func WriteFile(ctx context.Context, dest string, content []byte, perm fs.FileMode) error {
+	r := getRecorder(ctx)
+	out, name, err := r.resolve(dest)
+	if err != nil {
+		return err
+	}
+	change := newChange(out, name, "", dest, content, perm)
+	return r.record(change, func() error { return writeFile(out, name, change) })
+}
+
+// Remove removes dest like os.Remove would
+// and records the associated Change into Run returned changes.
+//
+// When Run is executed with WithDryRun, nothing is removed, the Change is only recorded.
+//
+// It should be used by parsers in place of os.Remove.
+func Remove(ctx context.Context, dest string) error {
+	return remove(ctx, Change{Dest: dest}, removeFile)
+}
+
+// RemoveAll removes dest and any children it contains like os.RemoveAll would
+// and records the associated Change into Run returned changes.
+//
+// When Run is executed with WithDryRun, nothing is removed, the Change is only recorded.
+//
+// It should be used by parsers in place of os.RemoveAll.
+func RemoveAll(ctx context.Context, dest string) error {
+	return remove(ctx, Change{Dest: dest}, OutputFS.RemoveAll)
+}
+
+// remove records a removal change of change.Dest in case it exists and executes rm on it.
+//
+// The input change is completed with ActionRemove and Previous content.
+func remove(ctx context.Context, change Change, rm func(out OutputFS, name string) error) error {
+	r := getRecorder(ctx)
+	out, name, err := r.resolve(change.Dest)
+	if err != nil {
+		return err
+	}
+
+	target, symlink := readlink(out, name)
+	if _, err := fs.Stat(out, name); err != nil && !symlink {
+		return nil // nothing to remove, it doesn't exist or can't be accessed
+	}
+	change.Action = ActionRemove
+	if symlink {
+		change.Previous = []byte(target)
+	} else {
+		change.Previous, _ = fs.ReadFile(out, name) // directories can't be read, in that case previous content is empty
+	}
+	return r.record(change, func() error {
+		if err := rm(out, name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+}
+
+// removeFile removes name from out like os.Remove would, i.e. only if it's a file or an empty directory.
+func removeFile(out OutputFS, name string) error {
+	if _, ok := readlink(out, name); ok {
+		return out.RemoveAll(name) //nolint:wrapcheck
+	}
+	entries, err := fs.ReadDir(out, name)
+	if err == nil && len(entries) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+	return out.RemoveAll(name) //nolint:wrapcheck
+}
+
+// newChange computes the Change to write content into name (dest in out)
+// depending on whether it already exists or not and its current content and executable rights.
+//
+// When perm is a symbolic link (fs.ModeSymlink), content is the link target.
+func newChange(out OutputFS, name, src, dest string, content []byte, perm fs.FileMode) Change {
+	change := Change{Action: ActionUpdate, Content: content, Dest: dest, Mode: perm, Src: src}
+
+	// compare symbolic links with their target instead of the content they point to
+	if target, ok := readlink(out, name); ok || perm&fs.ModeSymlink != 0 {
+		switch {
+		case ok && perm&fs.ModeSymlink != 0 && target == string(content):
+			change.Action = ActionUnchanged
+		case ok:
+			change.Previous = []byte(target)
+		default:
+			if _, err := fs.Stat(out, name); err != nil {
+				change.Action = ActionCreate
+			} else {
+				change.Previous, _ = fs.ReadFile(out, name) // directories can't be read, in that case previous content is empty
+			}
+		}
+		return change
+	}
+
+	info, err := fs.Stat(out, name)
+	if err != nil {
+		change.Action = ActionCreate
+		return change
+	}
+	if info.IsDir() {
+		return change
+	}
+
+	previous, err := fs.ReadFile(out, name)
+	if err != nil {
+		return change
+	}
+	change.Previous = previous
+
+	// only executable rights are compared since others depend on user umask
+	if bytes.Equal(previous, content) && info.Mode().Perm()&0o111 == perm.Perm()&0o111 {
+		change.Action = ActionUnchanged
+	}
+	return change
+}
+
+// writeFile writes the input change into name in out
+// while creating its directory and refreshing its rights.
+func writeFile(out OutputFS, name string, change Change) error {
+	if change.Action == ActionUnchanged {
+		return nil
+	}
+
+	// create destination directory only if one file would be generated
+	if err := out.MkdirAll(path.Dir(name), cfs.RwxRxRxRx); err != nil && !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	// existing symbolic links are replaced instead of writing into their target
+	if _, ok := readlink(out, name); ok {
+		if err := out.RemoveAll(name); err != nil {
+			return fmt.Errorf("remove symlink: %w", err)
+		}
+	}
+
+	if change.Mode&fs.ModeSymlink != 0 {
+		symlinks, ok := out.(SymlinkFS)
+		if !ok {
+			return errors.New("symbolic links aren't supported by output")
+		}
+		if err := checkSymlink(name, string(change.Content)); err != nil {
+			return err
+		}
+		if info, err := fs.Stat(out, name); err == nil { // existing regular file
+			if info.IsDir() {
+				return &fs.PathError{Op: "symlink", Path: name, Err: errors.New("is a directory")}
+			}
+			if err := out.RemoveAll(name); err != nil {
+				return fmt.Errorf("remove file: %w", err)
+			}
+		}
+		if err := symlinks.Symlink(string(change.Content), name); err != nil {
+			return fmt.Errorf("symlink: %w", err)
+		}
+		return nil
+	}
+
+	if err := out.WriteFile(name, change.Content, change.Mode); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	return nil
+}
+
+// ErrUnsafeSymlink is the error returned (wrapped) when a generated symbolic link target isn't inside destination directory.
+//
+// Since templates can come from external sources, such links could make later generated files be written anywhere.
+var ErrUnsafeSymlink = errors.New("symbolic link target isn't inside destination directory")
+
+// checkSymlink returns an error in case target (of name symbolic link) is absolute or leads outside of destination directory.
+func checkSymlink(name, target string) error {
+	if target == "" || path.IsAbs(target) || filepath.IsAbs(target) ||
+		!filepath.IsLocal(filepath.FromSlash(path.Join(path.Dir(name), filepath.ToSlash(target)))) {
+		return fmt.Errorf("'%s' -> '%s': %w", name, target, ErrUnsafeSymlink)
+	}
+	return nil
+}
+
+// readlink returns the target of name in out in case out supports symbolic links (see SymlinkFS) and name is one.
+func readlink(out OutputFS, name string) (string, bool) {
+	symlinks, ok := out.(SymlinkFS)
+	if !ok {
+		return "", false
+	}
+	target, err := symlinks.Readlink(name)
+	return target, err == nil
+}
+
+// recorder keeps track of all changes done (or to be done in dry run) during Run.
+type recorder struct {
+	dryRun   bool
+	buffered bool // whether changes (even without apply) are only kept to be recorded later in another recorder
+	stage    bool // whether changes are staged until commit or applied as soon as they're recorded
+
+	destdir  string
+	excluded func(rel string) bool // whether a path relative to destdir is excluded (see craft.Configuration IsExcluded)
+	out      OutputFS
+
+	mu      sync.Mutex
+	changes []Change
+	staged  []staged
+}
+
+// resolve returns the OutputFS and the name in it of the input dest path.
+//
+// When not executed in Run context (no OutputFS), dest is resolved directly on OS.
+func (r *recorder) resolve(dest string) (OutputFS, string, error) {
+	if r.out == nil {
+		return OSOutput(filepath.Dir(dest)), filepath.Base(dest), nil
+	}
+	rel, err := filepath.Rel(r.destdir, dest)
+	if err != nil {
+		return nil, "", fmt.Errorf("relative path: %w", err)
+	}
+	name := filepath.ToSlash(rel)
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: "resolve", Path: dest, Err: errors.New("outside of destination directory")}
+	}
+	return r.out, name, nil
+}
+
+// record applies (if not in dry run and apply isn't nil) and saves the input change.
+//
+// When the recorder stages changes, apply is only kept to be executed during commit.
+//
+// Changes of excluded paths (see isExcluded) are never applied, they're saved as skipped changes
+// whether they come from handlers or parsers (e.g. a license written or a chart removed).
+func (r *recorder) record(change Change, apply func() error) error {
+	if change.Action != ActionSkip && r.isExcluded(change) {
+		change = Change{Action: ActionSkip, Dest: change.Dest, Handler: change.Handler, Reason: ReasonExcluded, Src: change.Src}
+		apply = nil
+	}
+
+	if r.buffered {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.staged = append(r.staged, staged{change: change, apply: apply})
+		return nil
+	}
+
+	if !r.dryRun && apply != nil && !r.stage {
+		if err := apply(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+	if !r.dryRun && apply != nil && r.stage {
+		r.staged = append(r.staged, staged{change: change, apply: apply})
+	}
+	return nil
+}
+
+// isExcluded returns truthy in case change destination is excluded,
+// or contains excluded files for removals (e.g. a directory removed with RemoveAll).
+func (r *recorder) isExcluded(change Change) bool {
+	if r.excluded == nil {
+		return false
+	}
+	rel := lockKey(r.destdir, change.Dest)
+	if r.excluded(rel) {
+		return true
+	}
+	if change.Action != ActionRemove || r.out == nil {
+		return false
+	}
+
+	var excluded bool
+	_ = fs.WalkDir(r.out, rel, func(name string, _ fs.DirEntry, err error) error {
+		if err == nil && r.excluded(name) {
+			excluded = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return excluded
+}
+
+type recorderKeyType string
+
+const recorderKey recorderKeyType = "recorder"
+
+// getRecorder returns the context recorder.
+//
+// When not executed in Run context, a new recorder is returned
+// meaning that changes are applied but not kept.
+func getRecorder(ctx context.Context) *recorder {
+	r, ok := ctx.Value(recorderKey).(*recorder)
+	if !ok {
+		return &recorder{}
+	}
+	return r
+}
+
+// IsDryRun returns truthy when the input context is the one of a Run executed with WithDryRun.
+func IsDryRun(ctx context.Context) bool {
+	return getRecorder(ctx).dryRun
+}
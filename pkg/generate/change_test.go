@@ -0,0 +1,151 @@
+package generate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+func TestWriteFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("error_write", func(t *testing.T) {
+		// Arrange
+		dest := filepath.Join(t.TempDir(), "dir")
+		require.NoError(t, os.Mkdir(dest, cfs.RwxRxRxRx))
+
+		// Act
+		err := generate.WriteFile(ctx, dest, []byte("content"), cfs.RwRR)
+
+		// Assert
+		assert.ErrorContains(t, err, "write file")
+	})
+
+	t.Run("success_outside_run", func(t *testing.T) {
+		// Arrange
+		dest := filepath.Join(t.TempDir(), "dir", "file.txt")
+
+		// Act
+		err := generate.WriteFile(ctx, dest, []byte("content"), cfs.RwxRxRxRx)
+
+		// Assert
+		require.NoError(t, err)
+		bytes, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, "content", string(bytes))
+		info, err := os.Stat(dest)
+		require.NoError(t, err)
+		assert.Equal(t, cfs.RwxRxRxRx, info.Mode().Perm())
+	})
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("error_not_empty_dir", func(t *testing.T) {
+		// Arrange
+		dest := filepath.Join(t.TempDir(), "dir")
+		require.NoError(t, os.MkdirAll(filepath.Join(dest, "subdir"), cfs.RwxRxRxRx))
+
+		// Act
+		err := generate.Remove(ctx, dest)
+
+		// Assert
+		assert.Error(t, err)
+		assert.DirExists(t, dest)
+	})
+
+	t.Run("success_not_exists", func(t *testing.T) {
+		// Arrange
+		dest := filepath.Join(t.TempDir(), "file.txt")
+
+		// Act
+		err := generate.Remove(ctx, dest)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("success_remove_all", func(t *testing.T) {
+		// Arrange
+		dest := filepath.Join(t.TempDir(), "dir")
+		require.NoError(t, os.MkdirAll(filepath.Join(dest, "subdir"), cfs.RwxRxRxRx))
+
+		// Act
+		err := generate.RemoveAll(ctx, dest)
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoDirExists(t, dest)
+	})
+}
+
+func TestIsDryRun(t *testing.T) {
+	t.Run("success_outside_run", func(t *testing.T) {
+		// Act
+		dryRun := generate.IsDryRun(context.Background())
+
+		// Assert
+		assert.False(t, dryRun)
+	})
+}
+
+func TestChange_Diff(t *testing.T) {
+	t.Run("success_create", func(t *testing.T) {
+		// Arrange
+		change := generate.Change{Action: generate.ActionCreate, Content: []byte("line\n")}
+		expected := "--- /dev/null\n+++ b/file.txt\n@@ -0,0 +1 @@\n+line\n"
+
+		// Act
+		diff := change.Diff("file.txt")
+
+		// Assert
+		assert.Equal(t, expected, diff)
+	})
+
+	t.Run("success_update", func(t *testing.T) {
+		// Arrange
+		change := generate.Change{
+			Action:   generate.ActionUpdate,
+			Content:  []byte("first\nsecond"),
+			Previous: []byte("first\nthird\n"),
+		}
+		expected := "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n first\n-third\n+second\n\\ No newline at end of file\n"
+
+		// Act
+		diff := change.Diff("file.txt")
+
+		// Assert
+		assert.Equal(t, expected, diff)
+	})
+
+	t.Run("success_remove", func(t *testing.T) {
+		// Arrange
+		change := generate.Change{Action: generate.ActionRemove, Previous: []byte("line\n")}
+		expected := "--- a/file.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-line\n"
+
+		// Act
+		diff := change.Diff("file.txt")
+
+		// Assert
+		assert.Equal(t, expected, diff)
+	})
+
+	t.Run("success_skip", func(t *testing.T) {
+		// Arrange
+		change := generate.Change{Action: generate.ActionSkip, Reason: "user owned"}
+
+		// Act
+		diff := change.Diff("file.txt")
+
+		// Assert
+		assert.Empty(t, diff)
+	})
+}
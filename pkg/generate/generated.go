@@ -0,0 +1,206 @@
+package generate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+)
+
+// generated is the string for generated files.
+const generated = "Code generated by craft; DO NOT EDIT."
+
+// generatedRegexp matches the generated header of generated files,
+// with or without craft version and source template (see header).
+var generatedRegexp = regexp.MustCompile(`Code generated by craft(?: [^;]*)?; DO NOT EDIT\.`)
+
+// comment is the comment syntax of a file type (end being empty for line comments).
+type comment struct {
+	start string
+	end   string
+}
+
+var (
+	commentHash     = comment{start: "#"}
+	commentSlash    = comment{start: "//"}
+	commentHTML     = comment{start: "<!--", end: "-->"}
+	commentBlock    = comment{start: "/*", end: "*/"}
+	commentDash     = comment{start: "--"}
+	commentTemplate = comment{start: "{{/*", end: "*/}}"}
+)
+
+// comments is the comment syntax of files by extension (or by name for files without extension).
+var comments = map[string]comment{
+	// hash comments
+	".bash":          commentHash,
+	".cfg":           commentHash,
+	".conf":          commentHash,
+	".dockerignore":  commentHash,
+	".env":           commentHash,
+	".gitattributes": commentHash,
+	".gitignore":     commentHash,
+	".helmignore":    commentHash,
+	".mk":            commentHash,
+	".npmignore":     commentHash,
+	".properties":    commentHash,
+	".py":            commentHash,
+	".rb":            commentHash,
+	".sh":            commentHash,
+	".tf":            commentHash,
+	".toml":          commentHash,
+	".yaml":          commentHash,
+	".yml":           commentHash,
+	".zsh":           commentHash,
+	"Dockerfile":     commentHash,
+	"Makefile":       commentHash,
+
+	// slash comments
+	".c":     commentSlash,
+	".cjs":   commentSlash,
+	".go":    commentSlash,
+	".java":  commentSlash,
+	".js":    commentSlash,
+	".json5": commentSlash,
+	".jsonc": commentSlash,
+	".kt":    commentSlash,
+	".mjs":   commentSlash,
+	".proto": commentSlash,
+	".rs":    commentSlash,
+	".ts":    commentSlash,
+
+	// other comments
+	".css":  commentBlock,
+	".html": commentHTML,
+	".lua":  commentDash,
+	".md":   commentHTML,
+	".sql":  commentDash,
+	".tpl":  commentTemplate, // helm templates helpers
+	".xml":  commentHTML,
+}
+
+// IsGenerated returns truthy if input destination is a generated file.
+//
+// Symbolic links are always considered as generated files since they can't have any header
+// (their manual modifications are still detected with the lock file, see WithLock).
+//
+// Files without the generated header (e.g. files without any known comment syntax, see header)
+// are also generated files when they're in the lock file with the same checksum (see isLocked).
+func IsGenerated(dest string) bool {
+	if _, err := os.Readlink(dest); err == nil {
+		return true
+	}
+
+	// retrieve file content, if there's an error, generation to make
+	content, err := os.ReadFile(dest)
+	if err != nil {
+		return true
+	}
+	return isGenerated(content) || isLocked(dest, content)
+}
+
+// isLocked returns truthy if dest is in the closest lock file (craft.LockFile) of its parent directories
+// with content checksum, i.e. it wasn't modified since last generation.
+func isLocked(dest string, content []byte) bool {
+	abs, err := filepath.Abs(dest)
+	if err != nil {
+		return false
+	}
+	for dir := filepath.Dir(abs); ; {
+		if _, err := os.Stat(filepath.Join(dir, craft.LockFile)); err == nil {
+			lock, err := ReadLock(dir)
+			if err != nil {
+				return false
+			}
+			entry, ok := lock.Files[lockKey(dir, abs)]
+			return ok && entry.Checksum == Checksum(content)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// isGenerated returns truthy if input content is the one of a generated file.
+func isGenerated(content []byte) bool {
+	// special case (shouldn't happen) where the destination has been replaced with an empty file
+	if len(content) == 0 {
+		return true
+	}
+	lines := strings.Split(string(content), "\n")
+
+	// check first line for generated regexp
+	if len(lines) >= 1 && generatedRegexp.MatchString(lines[0]) {
+		return true
+	}
+
+	// check second line for generated regexp
+	if len(lines) >= 2 && generatedRegexp.MatchString(lines[1]) {
+		return true
+	}
+	return false
+}
+
+// header returns the generated header of dest with craft version and source template,
+// in dest comment syntax (e.g. "# Code generated by craft v1.0.0 from Makefile.tmpl; DO NOT EDIT.").
+//
+// An empty header is returned in case dest comment syntax is unknown.
+func header(dest, version, template string) string {
+	name := filepath.Base(dest)
+	syntax, ok := comments[filepath.Ext(name)]
+	if !ok {
+		syntax, ok = comments[name]
+	}
+	if !ok {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString(syntax.start + " Code generated by craft")
+	if version != "" {
+		builder.WriteString(" " + version)
+	}
+	if template != "" {
+		builder.WriteString(" from " + template)
+	}
+	builder.WriteString("; DO NOT EDIT.")
+	if syntax.end != "" {
+		builder.WriteString(" " + syntax.end)
+	}
+	return builder.String()
+}
+
+// withHeader returns content with header as first line (second one when content starts with a shebang),
+// followed by an empty line.
+//
+// A generated header already written by content (e.g. custom templates written before headers were added by craft)
+// is replaced by header to avoid having it twice.
+//
+// Content is returned as is when header is empty.
+func withHeader(content []byte, header string) []byte {
+	if header == "" {
+		return content
+	}
+
+	var result bytes.Buffer
+	if bytes.HasPrefix(content, []byte("#!")) {
+		shebang, rest, _ := bytes.Cut(content, []byte("\n"))
+		result.Write(shebang)
+		result.WriteString("\n")
+		content = rest
+	}
+	if first, rest, _ := bytes.Cut(content, []byte("\n")); generatedRegexp.Match(first) {
+		content = rest
+	}
+	result.WriteString(header + "\n")
+	if content = bytes.TrimLeft(content, "\n"); len(content) > 0 {
+		result.WriteString("\n")
+		result.Write(content)
+	}
+	return result.Bytes()
+}
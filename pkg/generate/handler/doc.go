@@ -32,7 +32,7 @@ Example:
 
 	// fully used with generate.Run
 	func main() {
-		config, err := generate.Run(ctx, config, generate.WithHandlers(handler.Defaults()...))
+		config, report, err := generate.Run(ctx, config, generate.WithHandlers(handler.Defaults()...))
 		// handle err
 	}
 */
@@ -88,6 +88,19 @@ func TestIsGenerated(t *testing.T) {
 		assert.True(t, generated)
 	})
 
+	t.Run("generated_version_template", func(t *testing.T) {
+		// Arrange
+		dest := filepath.Join(t.TempDir(), "file.txt")
+		err := os.WriteFile(dest, []byte("#!/bin/sh\n# Code generated by craft v1.0.0 from launcher.sh.tmpl; DO NOT EDIT."), cfs.RwRR)
+		require.NoError(t, err)
+
+		// Act
+		generated := handler.IsGenerated(dest)
+
+		// Assert
+		assert.True(t, generated)
+	})
+
 	t.Run("generated_second_line", func(t *testing.T) {
 		// Arrange
 		dest := filepath.Join(t.TempDir(), "file.txt")
@@ -7,6 +7,7 @@ import (
 
 	"github.com/kilianpaquier/craft/pkg/craft"
 	"github.com/kilianpaquier/craft/pkg/generate"
+	"github.com/kilianpaquier/craft/pkg/generate/parser"
 )
 
 // GitHub is the handler for GitHub specific files generation.
@@ -51,8 +52,7 @@ func githubWorkflow(src, dest, name string) (generate.HandlerResult, bool) {
 		}
 	case "dependencies.yml":
 		result.ShouldRemove = func(metadata generate.Metadata) bool {
-			_, ok := metadata.Languages["golang"]
-			return !ok || !metadata.IsCI(craft.GitHub)
+			return !parser.LanguageGolang.Has(metadata) || !metadata.IsCI(craft.GitHub)
 		}
 	case "labeler.yml":
 		result.ShouldRemove = func(metadata generate.Metadata) bool {
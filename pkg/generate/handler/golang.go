@@ -4,6 +4,7 @@ import (
 	"slices"
 
 	"github.com/kilianpaquier/craft/pkg/generate"
+	"github.com/kilianpaquier/craft/pkg/generate/parser"
 )
 
 // Golang is the handler for goreleaser option generation matching.
@@ -13,7 +14,7 @@ func Golang(src, dest, name string) (generate.HandlerResult, bool) {
 	}
 
 	// Go wasn't parsed during parsers processing
-	noGo := func(metadata generate.Metadata) bool { _, ok := metadata.Languages["golang"]; return !ok }
+	noGo := func(metadata generate.Metadata) bool { return !parser.LanguageGolang.Has(metadata) }
 
 	result := generate.HandlerResult{
 		Delimiter:      generate.DelimiterChevron(),
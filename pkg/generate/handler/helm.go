@@ -37,6 +37,7 @@ func helmTemplates(src, dest, name string) (generate.HandlerResult, bool) {
 	result := generate.HandlerResult{
 		Delimiter:      generate.DelimiterChevron(),
 		Globs:          []string{src},
+		NoValidation:   true, // chart templates are helm templates and as such aren't valid YAML files
 		ShouldGenerate: func(generate.Metadata) bool { return IsGenerated(dest) },
 		ShouldRemove:   func(metadata generate.Metadata) bool { return metadata.NoChart },
 	}
@@ -73,6 +74,7 @@ func helmConfig(src, dest, name string) (generate.HandlerResult, bool) {
 
 	switch name {
 	case craft.File:
+		result.NoHeader = true // chart craft file is user owned once generated
 		result.ShouldGenerate = func(generate.Metadata) bool { return !cfs.Exists(dest) }
 	case "values.yaml":
 		result.Globs = append(result.Globs, PartGlob(src, name))
@@ -8,6 +8,7 @@ import (
 
 	"github.com/kilianpaquier/craft/pkg/craft"
 	"github.com/kilianpaquier/craft/pkg/generate"
+	"github.com/kilianpaquier/craft/pkg/generate/parser"
 )
 
 // CodeCov is the handler for codecov generation.
@@ -62,8 +63,7 @@ func Docker(src, dest, name string) (generate.HandlerResult, bool) {
 		// launcher.sh is a specific thing to golang being able to have multiple binaries inside a simple project (cmd folder)
 		// however, it may change in the future with python (or rust or others ?) depending on flexibility in repositories layout
 		result.ShouldRemove = func(metadata generate.Metadata) bool {
-			_, ok := metadata.Languages["golang"]
-			return metadata.Docker == nil || metadata.Binaries <= 1 || !ok
+			return metadata.Docker == nil || metadata.Binaries <= 1 || !parser.LanguageGolang.Has(metadata)
 		}
 	default:
 		return generate.HandlerResult{}, false
@@ -96,8 +96,7 @@ func Makefile(src, dest, name string) (generate.HandlerResult, bool) {
 		Globs:          []string{src},
 		ShouldGenerate: func(generate.Metadata) bool { return IsGenerated(dest) },
 		ShouldRemove: func(metadata generate.Metadata) bool {
-			_, ok := metadata.Languages["node"] // don't generate makefiles with node
-			return metadata.NoMakefile || ok
+			return metadata.NoMakefile || parser.LanguageNode.Has(metadata) // don't generate makefiles with node
 		},
 	}
 	if name == "install.mk" || name == "build.mk" {
@@ -115,7 +114,9 @@ func Readme(src, dest, name string) (generate.HandlerResult, bool) {
 	result := generate.HandlerResult{
 		Delimiter:      generate.DelimiterBracket(),
 		Globs:          []string{src},
-		ShouldGenerate: func(metadata generate.Metadata) bool { return !metadata.NoReadme && !cfs.Exists(dest) },
+		Disabled:       func(metadata generate.Metadata) bool { return metadata.NoReadme },
+		NoHeader:       true, // README.md is user owned once generated
+		ShouldGenerate: func(generate.Metadata) bool { return !cfs.Exists(dest) },
 	}
 	return result, true
 }
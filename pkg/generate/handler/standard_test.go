@@ -356,6 +356,8 @@ func TestReadme(t *testing.T) {
 
 		// Assert
 		assert.Nil(t, result.ShouldRemove)
+		assert.True(t, result.Disabled(generate.Metadata{Configuration: craft.Configuration{NoReadme: true}}))
+		assert.False(t, result.Disabled(generate.Metadata{}))
 	})
 }
 
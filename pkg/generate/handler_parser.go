@@ -1,6 +1,11 @@
 package generate
 
-import "context"
+import (
+	"context"
+	"io/fs"
+
+	"github.com/kilianpaquier/craft/pkg/templating"
+)
 
 // Handler represents the function to retrieve specificities over an input file.
 //
@@ -13,15 +18,50 @@ type HandlerResult struct {
 	// during go template statements execution.
 	Delimiter
 
+	// FinalNewline ensures generated content ends with a single line ending (trailing empty lines being removed).
+	FinalNewline bool
+
 	// Globs is the slice of globs or specific files to parse during go templating.
 	//
 	// It allows the current file to be split into multiple template files
 	// with "define" go template statements to help readability.
 	Globs []string
 
+	// LineEnding is the line endings policy of generated content (kept as rendered by default).
+	LineEnding LineEnding
+
+	// Mode is the file mode of the generated file.
+	//
+	// By default, it's the one given by templating.Mode (e.g. executable shell scripts).
+	// It's useful for executable files without any specific extension (e.g. scripts/release or git hooks).
+	Mode fs.FileMode
+
+	// NoHeader disables the generated header (see IsGenerated) added at the beginning of generated content.
+	//
+	// It's useful for files owned by users once generated (e.g. README.md)
+	// since the header would make them considered as generated files.
+	NoHeader bool
+
+	// NoValidation disables the validation of generated content according to the file extension (see templating.Validate).
+	//
+	// It's useful for files being templates themselves (e.g. helm chart templates).
+	NoValidation bool
+
+	// Symlink generates the file as a symbolic link, the rendered template (without surrounding spaces) being its target.
+	//
+	// Generated header, line endings and validation don't apply to symbolic links.
+	Symlink bool
+
+	// Disabled function is run (if not nil) after Handler execution to check whether the current file is disabled for the project
+	// (e.g. disabled in configuration or for a language not detected) without having to remove it.
+	//
+	// Unlike ShouldGenerate, a disabled file is never generated (even when forced) nor are its managed regions.
+	Disabled func(metadata Metadata) bool
+
 	// ShouldGenerate function is run (if not nil) after Handler execution to check whether the current file should be generated or not.
 	//
-	// In case it must not be generated, then nothing is done.
+	// In case it must not be generated, then nothing is done
+	// except generating its managed regions when it's an existing file not generated by craft (user owned).
 	//
 	// Note that Remove function (if not nil) is executed
 	// before ShouldGenerate to check whether the current file should be removed from filesystem.
@@ -30,6 +70,69 @@ type HandlerResult struct {
 	// ShouldRemove function is run (if not nil) after Handler execution to check
 	// whether the current file should be removed from filesystem or not.
 	ShouldRemove func(metadata Metadata) bool
+
+	// shouldGenerate and shouldRemove take precedence over ShouldGenerate and ShouldRemove
+	// for handlers whose checks can fail (e.g. manifest expressions), making the generation fail.
+	shouldGenerate func(metadata Metadata) (bool, error)
+	shouldRemove   func(metadata Metadata) (bool, error)
+}
+
+// FailedResult returns a HandlerResult failing the generation with err when the handled file is generated (or rendered).
+//
+// It's useful for handlers unable to decide about a file (e.g. a plugin failing to answer, see plugin package)
+// since considering it as not handled would make generation go on as if the file wasn't a template anymore.
+func FailedResult(err error) HandlerResult {
+	fail := func(Metadata) (bool, error) { return false, err }
+	return HandlerResult{shouldGenerate: fail, shouldRemove: fail}
+}
+
+// disabled returns whether the file is disabled for the project according to r (falsy by default).
+func (r HandlerResult) disabled(metadata Metadata) bool {
+	return r.Disabled != nil && r.Disabled(metadata)
+}
+
+// generate returns whether the file must be generated according to r (truthy by default).
+func (r HandlerResult) generate(metadata Metadata) (bool, error) {
+	switch {
+	case r.shouldGenerate != nil:
+		return r.shouldGenerate(metadata)
+	case r.ShouldGenerate != nil:
+		return r.ShouldGenerate(metadata), nil
+	default:
+		return true, nil
+	}
+}
+
+// remove returns whether the file must be removed according to r (falsy by default).
+func (r HandlerResult) remove(metadata Metadata) (bool, error) {
+	switch {
+	case r.shouldRemove != nil:
+		return r.shouldRemove(metadata)
+	case r.ShouldRemove != nil:
+		return r.ShouldRemove(metadata), nil
+	default:
+		return false, nil
+	}
+}
+
+// mode returns the file mode of dest generated with r.
+func (r HandlerResult) mode(dest string) fs.FileMode {
+	switch {
+	case r.Symlink:
+		return fs.ModeSymlink | fs.ModePerm
+	case r.Mode != 0:
+		return r.Mode
+	default:
+		return templating.Mode(dest)
+	}
+}
+
+// validate validates content according to dest extension (see templating.Validate) unless NoValidation is truthy.
+func (r HandlerResult) validate(dest string, content []byte) error {
+	if r.NoValidation || r.Symlink {
+		return nil
+	}
+	return templating.Validate(dest, content) //nolint:wrapcheck
 }
 
 // Parser is the function to parse a specific part of destdir repository.
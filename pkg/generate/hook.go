@@ -0,0 +1,95 @@
+package generate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/clog"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+)
+
+// runHooks runs all input hooks sequentially in destdir with their output routed through ctx logger.
+//
+// A failing hook stops the execution and its error is returned, unless it's configured to only warn on error.
+func runHooks(ctx context.Context, destdir, kind string, hooks []craft.Hook) error {
+	for _, hook := range hooks {
+		if err := runHook(ctx, destdir, hook); err != nil {
+			if hook.IsWarnOnError() {
+				GetLogger(ctx).Warnf("%s hook '%s' failed: %s", kind, hook.Command, err.Error())
+				continue
+			}
+			return fmt.Errorf("%s hook '%s': %w", kind, hook.Command, err)
+		}
+	}
+	return nil
+}
+
+// runHook runs the input hook command with sh in destdir (or hook directory relative to destdir).
+func runHook(ctx context.Context, destdir string, hook craft.Hook) error {
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	log := GetLogger(ctx)
+	log.Infof("running hook '%s'", hook.Command)
+
+	out := &logWriter{log: log, prefix: hook.Command}
+	defer out.flush()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Dir = filepath.Join(destdir, hook.Dir)
+	cmd.Env = os.Environ()
+	for _, key := range slices.Sorted(maps.Keys(hook.Env)) {
+		cmd.Env = append(cmd.Env, key+"="+hook.Env[key])
+	}
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.WaitDelay = time.Second // don't wait indefinitely for output of subprocesses still running after command was killed
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timeout after %s: %w", hook.Timeout, err)
+		}
+		return err //nolint:wrapcheck
+	}
+	return nil
+}
+
+// logWriter is an io.Writer logging each written line with Infof.
+type logWriter struct {
+	buf    bytes.Buffer
+	log    clog.Logger
+	prefix string
+}
+
+// Write logs all complete lines of p, incomplete ones are kept until next Write or flush.
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			w.buf.WriteString(line) // incomplete line, keep it for next write
+			return len(p), nil
+		}
+		w.log.Infof("%s: %s", w.prefix, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// flush logs the remaining incomplete line (if any).
+func (w *logWriter) flush() {
+	if w.buf.Len() > 0 {
+		w.log.Infof("%s: %s", w.prefix, w.buf.String())
+		w.buf.Reset()
+	}
+}
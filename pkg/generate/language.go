@@ -0,0 +1,55 @@
+package generate
+
+// Language is a typed key of Metadata Languages, T being the type of the language specificities.
+//
+// It allows parsers to set languages and handlers (or any Go code) to retrieve them with type safety,
+// while templates still access them by name (e.g. {{ hasKey .Languages "golang" }} or {{ get .Languages "node" }}).
+//
+// Example:
+//
+//	const LanguagePython generate.Language[Pyproject] = "python"
+//
+//	func Python(ctx context.Context, destdir string, metadata *generate.Metadata) error {
+//		// parse pyproject.toml
+//		LanguagePython.Set(metadata, pyproject)
+//		return nil
+//	}
+type Language[T any] string
+
+// Name returns the language name (its key in Metadata Languages).
+func (l Language[T]) Name() string {
+	return string(l)
+}
+
+// Field returns the language field name to use in ParserNode Consumes or Provides (e.g. "Languages.golang").
+func (l Language[T]) Field() string {
+	return "Languages." + string(l)
+}
+
+// Set sets the language specificities in metadata Languages.
+func (l Language[T]) Set(metadata *Metadata, specificities T) {
+	if metadata.Languages == nil {
+		metadata.Languages = map[string]any{}
+	}
+	metadata.Languages[string(l)] = specificities
+}
+
+// Get returns the language specificities from metadata Languages
+// and whether the language is present (a nil value being returned as T zero value).
+//
+// In case the language is present with another type than T, it's considered as missing.
+func (l Language[T]) Get(metadata Metadata) (T, bool) {
+	var zero T
+	value, ok := metadata.Languages[string(l)]
+	if !ok || value == nil {
+		return zero, ok
+	}
+	specificities, ok := value.(T)
+	return specificities, ok
+}
+
+// Has returns truthy in case the language is present in metadata Languages.
+func (l Language[T]) Has(metadata Metadata) bool {
+	_, ok := metadata.Languages[string(l)]
+	return ok
+}
@@ -0,0 +1,67 @@
+package generate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+func TestLanguage(t *testing.T) {
+	type specificities struct{ Version string }
+	const python generate.Language[specificities] = "python"
+
+	t.Run("success_set_get", func(t *testing.T) {
+		// Arrange
+		var metadata generate.Metadata
+
+		// Act
+		python.Set(&metadata, specificities{Version: "3.13"})
+
+		// Assert
+		actual, ok := python.Get(metadata)
+		assert.True(t, ok)
+		assert.Equal(t, specificities{Version: "3.13"}, actual)
+		assert.True(t, python.Has(metadata))
+		assert.Equal(t, map[string]any{"python": specificities{Version: "3.13"}}, metadata.Languages)
+	})
+
+	t.Run("success_nil", func(t *testing.T) {
+		// Arrange
+		metadata := generate.Metadata{Languages: map[string]any{"python": nil}}
+
+		// Act
+		actual, ok := python.Get(metadata)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Zero(t, actual)
+		assert.True(t, python.Has(metadata))
+	})
+
+	t.Run("success_missing", func(t *testing.T) {
+		// Arrange
+		metadata := generate.Metadata{Languages: map[string]any{"golang": nil}}
+
+		// Act
+		_, ok := python.Get(metadata)
+
+		// Assert
+		assert.False(t, ok)
+		assert.False(t, python.Has(metadata))
+		assert.Equal(t, "Languages.python", python.Field())
+	})
+
+	t.Run("error_type_mismatch", func(t *testing.T) {
+		// Arrange
+		metadata := generate.Metadata{Languages: map[string]any{"python": "3.13"}}
+
+		// Act
+		_, ok := python.Get(metadata)
+
+		// Assert
+		assert.False(t, ok)
+		assert.True(t, python.Has(metadata))
+	})
+}
@@ -0,0 +1,157 @@
+package generate
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+)
+
+// TemplateLayer returns the templates layer of dir in fsys to be given to WithTemplateLayers.
+//
+// For instance, TemplateLayer("/path/to/org/templates", cfs.OS()) or TemplateLayer("templates", embedded).
+func TemplateLayer(dir string, fsys cfs.FS) cfs.FS {
+	return subFS{dir: dir, fsys: fsys}
+}
+
+// subFS is a cfs.FS whose root is dir in fsys.
+type subFS struct {
+	dir  string
+	fsys cfs.FS
+}
+
+var _ cfs.FS = subFS{} // ensure interface is implemented
+
+func (s subFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(s.dir, name), nil
+}
+
+// Open opens name in dir.
+func (s subFS) Open(name string) (fs.File, error) {
+	full, err := s.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.Open(full) //nolint:wrapcheck
+}
+
+// ReadDir reads the directory name in dir.
+func (s subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := s.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.ReadDir(full) //nolint:wrapcheck
+}
+
+// ReadFile reads the file name in dir.
+func (s subFS) ReadFile(name string) ([]byte, error) {
+	full, err := s.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.ReadFile(full) //nolint:wrapcheck
+}
+
+// overlayFS is a cfs.FS merging multiple templates layers under root directory,
+// files from last layers taking precedence over the ones from first layers.
+type overlayFS struct {
+	root   string
+	layers []cfs.FS
+}
+
+var _ cfs.FS = overlayFS{} // ensure interface is implemented
+
+// rel returns name relative to overlay root, or false when name isn't in it.
+func (o overlayFS) rel(name string) (string, bool) {
+	switch {
+	case o.root == ".":
+		return name, true
+	case name == o.root:
+		return ".", true
+	default:
+		return strings.CutPrefix(name, o.root+"/")
+	}
+}
+
+// Open opens name from the last layer having it.
+//
+// In case name is a directory, its entries are the merged ones from all layers (see ReadDir).
+func (o overlayFS) Open(name string) (fs.File, error) {
+	file, err := lookup(o, "open", name, func(layer cfs.FS, rel string) (fs.File, error) { return layer.Open(rel) })
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil || !info.IsDir() {
+		return file, err //nolint:wrapcheck
+	}
+	_ = file.Close()
+
+	entries, err := o.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &dirFile{entries: entries, info: info}, nil
+}
+
+// ReadFile reads name from the last layer having it.
+func (o overlayFS) ReadFile(name string) ([]byte, error) {
+	return lookup(o, "readfile", name, func(layer cfs.FS, rel string) ([]byte, error) { return layer.ReadFile(rel) })
+}
+
+// ReadDir reads the directory name in all layers and merges their entries,
+// an entry from last layers taking precedence over the same entry from first layers.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	rel, ok := o.rel(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	var found bool
+	merged := map[string]fs.DirEntry{}
+	for _, layer := range o.layers {
+		entries, err := layer.ReadDir(rel)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err //nolint:wrapcheck
+		}
+		found = true
+		for _, entry := range entries {
+			merged[entry.Name()] = entry
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := slices.Collect(maps.Values(merged))
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+// lookup executes get on all layers (from last to first) and returns the first result not being a fs.ErrNotExist error.
+func lookup[T any](o overlayFS, op, name string, get func(layer cfs.FS, rel string) (T, error)) (T, error) {
+	var zero T
+	rel, ok := o.rel(name)
+	if !ok {
+		return zero, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	for _, layer := range slices.Backward(o.layers) {
+		result, err := get(layer, rel)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return result, err
+		}
+	}
+	return zero, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,61 @@
+package generate //nolint:testpackage
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayFS(t *testing.T) {
+	base := fstest.MapFS{
+		"_templates/a.tmpl":     &fstest.MapFile{Data: []byte("base a")},
+		"_templates/dir/b.tmpl": &fstest.MapFile{Data: []byte("base b")},
+	}
+	layer := fstest.MapFS{
+		"org/a.tmpl":     &fstest.MapFile{Data: []byte("org a")},
+		"org/dir/c.tmpl": &fstest.MapFile{Data: []byte("org c")},
+	}
+	overlay := overlayFS{root: "_templates", layers: []cfs.FS{TemplateLayer("_templates", base), TemplateLayer("org", layer)}}
+
+	t.Run("success_read_file", func(t *testing.T) {
+		// Act
+		a, err := overlay.ReadFile("_templates/a.tmpl")
+		require.NoError(t, err)
+		b, err := fs.ReadFile(overlay, "_templates/dir/b.tmpl")
+		require.NoError(t, err)
+
+		// Assert
+		assert.Equal(t, "org a", string(a))
+		assert.Equal(t, "base b", string(b))
+	})
+
+	t.Run("error_not_in_root", func(t *testing.T) {
+		// Act
+		_, err := overlay.ReadFile("org/a.tmpl")
+
+		// Assert
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("success_glob", func(t *testing.T) {
+		// Act
+		matches, err := fs.Glob(overlay, "_templates/dir/*.tmpl")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"_templates/dir/b.tmpl", "_templates/dir/c.tmpl"}, matches)
+	})
+
+	t.Run("success_fs", func(t *testing.T) {
+		// Arrange
+		sub, err := fs.Sub(overlay, "_templates")
+		require.NoError(t, err)
+
+		// Act & Assert
+		assert.NoError(t, fstest.TestFS(sub, "a.tmpl", "dir/b.tmpl", "dir/c.tmpl"))
+	})
+}
@@ -0,0 +1,48 @@
+package generate
+
+import (
+	"bytes"
+)
+
+// LineEnding represents the line endings of a generated file (see HandlerResult).
+type LineEnding string
+
+const (
+	// LineEndingKeep keeps line endings as rendered by the template (default).
+	LineEndingKeep LineEnding = ""
+
+	// LineEndingLF converts all line endings to \n (Unix style).
+	LineEndingLF LineEnding = "lf"
+
+	// LineEndingCRLF converts all line endings to \r\n (Windows style, e.g. for .bat or .ps1 files).
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// normalize returns content with all its line endings converted to l.
+//
+// When finalNewline is truthy, content trailing line endings are also replaced by a single one (empty content aside).
+func (l LineEnding) normalize(content []byte, finalNewline bool) []byte {
+	if l == LineEndingKeep && !finalNewline {
+		return content
+	}
+
+	eol := []byte("\n")
+	if l == LineEndingCRLF || (l == LineEndingKeep && bytes.Contains(content, []byte("\r\n"))) {
+		eol = []byte("\r\n")
+	}
+	if l != LineEndingKeep {
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		if l == LineEndingCRLF {
+			content = bytes.ReplaceAll(content, []byte("\n"), eol)
+		}
+	}
+
+	if finalNewline {
+		trimmed := bytes.TrimRight(content, "\r\n")
+		if len(trimmed) == 0 {
+			return trimmed
+		}
+		content = append(trimmed[:len(trimmed):len(trimmed)], eol...) // copy to avoid modifying input content
+	}
+	return content
+}
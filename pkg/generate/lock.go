@@ -0,0 +1,92 @@
+package generate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+)
+
+// Lock represents the generation lock file (craft.LockFile) content.
+//
+// It's written at the end of Run (when WithLock is given) and read at its beginning
+// to know whether a generated file was modified manually since last generation.
+type Lock struct {
+	// Files is the map of generated files (relative to destination directory and slash separated) with their lock entry.
+	Files map[string]LockEntry `yaml:"files,omitempty"`
+}
+
+// LockEntry represents a generated file in Lock.
+type LockEntry struct {
+	// Checksum is the generated file content checksum (see Checksum).
+	Checksum string `yaml:"checksum"`
+
+	// Template is the template path (relative to templates directory) from which the file was generated.
+	Template string `yaml:"template"`
+
+	// Version is the craft version (see WithVersion) which last modified the generated file.
+	Version string `yaml:"version,omitempty"`
+}
+
+// Checksum returns the checksum of input content as stored in LockEntry.
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ReadLock reads the lock file (craft.LockFile) in destdir.
+//
+// An empty Lock is returned in case the lock file doesn't exist.
+func ReadLock(destdir string) (Lock, error) {
+	return readLock(os.DirFS(destdir))
+}
+
+// readLock reads the lock file (craft.LockFile) at the root of fsys.
+func readLock(fsys fs.FS) (Lock, error) {
+	content, err := fs.ReadFile(fsys, craft.LockFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Lock{Files: map[string]LockEntry{}}, nil
+		}
+		return Lock{}, fmt.Errorf("read file: %w", err)
+	}
+
+	var lock Lock
+	if err := yaml.Unmarshal(content, &lock); err != nil {
+		return Lock{}, fmt.Errorf("unmarshal: %w", err)
+	}
+	if lock.Files == nil {
+		lock.Files = map[string]LockEntry{}
+	}
+	return lock, nil
+}
+
+// encode returns the lock file content of the Lock.
+func (l Lock) encode() ([]byte, error) {
+	buffer := bytes.NewBufferString("# " + generated + "\n\n")
+
+	encoder := yaml.NewEncoder(buffer)
+	defer encoder.Close()
+	encoder.SetIndent(2)
+	if err := encoder.Encode(l); err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// lockKey returns the Lock key of dest, i.e. dest relative to destdir and slash separated.
+func lockKey(destdir, dest string) string {
+	rel, err := filepath.Rel(destdir, dest)
+	if err != nil {
+		return filepath.ToSlash(dest)
+	}
+	return filepath.ToSlash(rel)
+}
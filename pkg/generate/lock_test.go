@@ -0,0 +1,62 @@
+package generate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+func TestChecksum(t *testing.T) {
+	// Act
+	checksum := generate.Checksum([]byte("content"))
+
+	// Assert
+	assert.Equal(t, "sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73", checksum)
+}
+
+func TestReadLock(t *testing.T) {
+	t.Run("success_not_exists", func(t *testing.T) {
+		// Act
+		lock, err := generate.ReadLock(t.TempDir())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, generate.Lock{Files: map[string]generate.LockEntry{}}, lock)
+	})
+
+	t.Run("error_unmarshal", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, craft.LockFile), []byte("files: invalid"), cfs.RwRR))
+
+		// Act
+		_, err := generate.ReadLock(destdir)
+
+		// Assert
+		assert.ErrorContains(t, err, "unmarshal")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		content := "files:\n  Makefile:\n    checksum: sha256:abc\n    template: Makefile.tmpl\n    version: v1.0.0\n"
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, craft.LockFile), []byte(content), cfs.RwRR))
+
+		// Act
+		lock, err := generate.ReadLock(destdir)
+
+		// Assert
+		require.NoError(t, err)
+		expected := generate.Lock{Files: map[string]generate.LockEntry{
+			"Makefile": {Checksum: "sha256:abc", Template: "Makefile.tmpl", Version: "v1.0.0"},
+		}}
+		assert.Equal(t, expected, lock)
+	})
+}
@@ -0,0 +1,252 @@
+package generate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/templating"
+)
+
+// manifest is the declarative handlers file (craft.ManifestFile) of a templates directory.
+type manifest struct {
+	Handlers []manifestHandler `yaml:"handlers"`
+}
+
+// manifestHandler is a declarative Handler of manifest.
+//
+// Generate and Remove are go template expressions executed with Metadata
+// and must return "true" or "false" (any other result or execution error makes the generation fail).
+type manifestHandler struct {
+	// Delimiter is either "bracket" (default), "chevron" or "square_bracket".
+	Delimiter string `yaml:"delimiter,omitempty"`
+
+	// FinalNewline ensures generated files end with a single line ending.
+	FinalNewline bool `yaml:"final_newline,omitempty"`
+
+	// Files is the slice of globs (see path.Match) of destination files handled,
+	// either relative to destination directory or only matching files names when they don't contain any "/".
+	Files []string `yaml:"files"`
+
+	// Generate is the expression to check whether the file must be generated.
+	// By default, files are generated when they don't exist or are generated files.
+	Generate string `yaml:"generate,omitempty"`
+
+	// LineEnding is either "lf" or "crlf" (line endings are kept as rendered by default).
+	LineEnding string `yaml:"line_ending,omitempty"`
+
+	// Mode is the octal file mode of generated files (e.g. "0755" for executable scripts).
+	Mode string `yaml:"mode,omitempty"`
+
+	// NoHeader disables the generated header added at the beginning of generated files.
+	NoHeader bool `yaml:"no_header,omitempty"`
+
+	// NoValidation disables the validation of generated files according to their extension (see templating.Validate).
+	NoValidation bool `yaml:"no_validation,omitempty"`
+
+	// Parts is the slice of templates parts globs, relative to the template file directory (e.g. "Dockerfile-*.part.tmpl").
+	Parts []string `yaml:"parts,omitempty"`
+
+	// Remove is the expression to check whether the file must be removed.
+	Remove string `yaml:"remove,omitempty"`
+
+	// Symlink generates files as symbolic links to their rendered template.
+	Symlink bool `yaml:"symlink,omitempty"`
+
+	delimiter Delimiter
+	generate  *template.Template
+	mode      fs.FileMode
+	remove    *template.Template
+	tmplDir   string
+}
+
+// readManifest reads craft.ManifestFile in tmplDir of fsys and returns its handlers.
+//
+// No handler is returned in case it doesn't exist.
+func readManifest(fsys cfs.FS, tmplDir string) ([]Handler, error) {
+	content, err := fsys.ReadFile(path.Join(tmplDir, craft.ManifestFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", craft.ManifestFile, err)
+	}
+
+	var m manifest
+	if err := yaml.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", craft.ManifestFile, err)
+	}
+
+	handlers := make([]Handler, 0, len(m.Handlers))
+	errs := make([]error, 0, len(m.Handlers))
+	for index, handler := range m.Handlers {
+		if err := handler.init(tmplDir); err != nil {
+			errs = append(errs, fmt.Errorf("%s handler %d: %w", craft.ManifestFile, index, err))
+			continue
+		}
+		handlers = append(handlers, handler.handle)
+	}
+	return handlers, errors.Join(errs...)
+}
+
+// init validates the handler and parses its expressions.
+func (h *manifestHandler) init(tmplDir string) error {
+	if len(h.Files) == 0 {
+		return errors.New("at least one file glob must be provided")
+	}
+	for _, glob := range h.Files {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid file glob '%s': %w", glob, err)
+		}
+	}
+
+	switch h.Delimiter {
+	case "", "bracket":
+		h.delimiter = DelimiterBracket()
+	case "chevron":
+		h.delimiter = DelimiterChevron()
+	case "square_bracket":
+		h.delimiter = DelimiterSquareBracket()
+	default:
+		return fmt.Errorf("invalid delimiter '%s', must be one of bracket, chevron or square_bracket", h.Delimiter)
+	}
+
+	switch LineEnding(h.LineEnding) {
+	case LineEndingKeep, LineEndingLF, LineEndingCRLF:
+	default:
+		return fmt.Errorf("invalid line_ending '%s', must be either lf or crlf", h.LineEnding)
+	}
+
+	if h.Mode != "" {
+		mode, err := strconv.ParseUint(strings.TrimPrefix(h.Mode, "0o"), 8, 32)
+		if err != nil || mode == 0 || mode > uint64(fs.ModePerm) {
+			return fmt.Errorf("invalid mode '%s', must be an octal file mode (e.g. 0755)", h.Mode)
+		}
+		h.mode = fs.FileMode(mode)
+	}
+
+	var err error
+	if h.generate, err = parseExpression("generate", h.Generate); err != nil {
+		return err
+	}
+	if h.remove, err = parseExpression("remove", h.Remove); err != nil {
+		return err
+	}
+	h.tmplDir = tmplDir
+	return nil
+}
+
+// handle is the Handler of manifestHandler.
+func (h manifestHandler) handle(src, dest, name string) (HandlerResult, bool) {
+	rel := strings.TrimSuffix(strings.TrimPrefix(src, h.tmplDir+"/"), craft.TmplExtension)
+	if !h.matches(rel, name) {
+		return HandlerResult{}, false
+	}
+
+	globs := []string{src}
+	for _, part := range h.Parts {
+		globs = append(globs, path.Join(path.Dir(src), part))
+	}
+	shouldGenerate := func(metadata Metadata) (bool, error) {
+		if h.generate == nil {
+			return IsGenerated(dest), nil
+		}
+		return evaluate(h.generate, h.Generate, dest, metadata)
+	}
+	shouldRemove := func(metadata Metadata) (bool, error) {
+		if h.remove == nil {
+			return false, nil
+		}
+		return evaluate(h.remove, h.Remove, dest, metadata)
+	}
+	return HandlerResult{
+		Delimiter:    h.delimiter,
+		FinalNewline: h.FinalNewline,
+		Globs:        globs,
+		LineEnding:   LineEnding(h.LineEnding),
+		Mode:         h.mode,
+		NoHeader:     h.NoHeader,
+		NoValidation: h.NoValidation,
+		Symlink:      h.Symlink,
+		ShouldGenerate: func(metadata Metadata) bool {
+			ok, _ := shouldGenerate(metadata)
+			return ok
+		},
+		ShouldRemove: func(metadata Metadata) bool {
+			ok, _ := shouldRemove(metadata)
+			return ok
+		},
+		shouldGenerate: shouldGenerate,
+		shouldRemove:   shouldRemove,
+	}, true
+}
+
+// matches returns truthy in case rel (or name for globs without "/") matches one of handler files globs.
+func (h manifestHandler) matches(rel, name string) bool {
+	for _, glob := range h.Files {
+		target := rel
+		if !strings.Contains(glob, "/") {
+			target = name
+		}
+		if ok, _ := path.Match(glob, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpression parses the input go template expression (nil is returned when it's empty).
+//
+// Besides sprig and templating functions, "exists" and "isGenerated" functions are available
+// to check the destination file.
+func parseExpression(name, expr string) (*template.Template, error) {
+	if expr == "" {
+		return nil, nil //nolint:nilnil
+	}
+	tmpl, err := template.New(name).
+		Funcs(sprig.FuncMap()).
+		Funcs(templating.FuncMap()).
+		Funcs(destFuncs("")).
+		Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s expression: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// evaluate executes tmpl (parsed from expr) with metadata and returns whether its result is "true".
+//
+// An error is returned in case the execution fails or its result is neither "true" nor "false".
+func evaluate(tmpl *template.Template, expr, dest string, metadata Metadata) (bool, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return false, fmt.Errorf("%s %s expression '%s': clone: %w", craft.ManifestFile, tmpl.Name(), expr, err)
+	}
+	var result bytes.Buffer
+	if err := clone.Funcs(destFuncs(dest)).Execute(&result, metadata); err != nil {
+		return false, fmt.Errorf("%s %s expression '%s': %w", craft.ManifestFile, tmpl.Name(), expr, err)
+	}
+	ok, err := strconv.ParseBool(strings.TrimSpace(result.String()))
+	if err != nil {
+		return false, fmt.Errorf("%s %s expression '%s': result '%s' must be either true or false", craft.ManifestFile, tmpl.Name(), expr, strings.TrimSpace(result.String()))
+	}
+	return ok, nil
+}
+
+// destFuncs returns the expressions functions related to dest file.
+func destFuncs(dest string) template.FuncMap {
+	return template.FuncMap{
+		"exists":      func() bool { return cfs.Exists(dest) },
+		"isGenerated": func() bool { return IsGenerated(dest) },
+	}
+}
@@ -0,0 +1,131 @@
+package generate_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+func TestRun_Manifest(t *testing.T) {
+	ctx := context.Background()
+
+	manifest := `handlers:
+  - files: [Dockerfile]
+    delimiter: chevron
+    parts: [Dockerfile-*.part.tmpl]
+    generate: '{{ and .Docker (isGenerated) }}'
+    remove: '{{ not .Docker }}'
+  - files: [".github/workflows/*.yml"]
+    generate: '{{ eq .Platform "github" }}'
+  - files: [scripts/release]
+    mode: "0755"
+    final_newline: true
+`
+	templates := fstest.MapFS{
+		"templates/" + craft.ManifestFile:             {Data: []byte(manifest)},
+		"templates/Dockerfile.tmpl":                   {Data: []byte(`<< template "base" . >>EXPOSE << .Docker.Port >>` + "\n")},
+		"templates/Dockerfile-base.part.tmpl":         {Data: []byte(`<< define "base" >>FROM scratch` + "\n" + `<< end >>`)},
+		"templates/.github/workflows/ci.yml.tmpl":     {Data: []byte("name: {{ .ProjectName }}\n")},
+		"templates/.github/workflows/ignored.md.tmpl": {Data: []byte("ignored\n")},
+		"templates/scripts/release.tmpl":              {Data: []byte("#!/bin/sh\necho release\n\n")},
+	}
+	port := uint16(8080)
+
+	run := func(destdir string, config craft.Configuration) error {
+		_, _, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithParsers(generate.ParserNoop),
+			generate.WithTemplates("templates", templates))
+		return err
+	}
+
+	t.Run("success_generated", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		err := run(destdir, craft.Configuration{Docker: &craft.Docker{Port: &port}, Platform: craft.GitHub})
+
+		// Assert
+		require.NoError(t, err)
+		dockerfile, err := os.ReadFile(filepath.Join(destdir, "Dockerfile"))
+		require.NoError(t, err)
+		assert.Equal(t, "# Code generated by craft from Dockerfile.tmpl; DO NOT EDIT.\n\nFROM scratch\nEXPOSE 8080\n", string(dockerfile))
+		assert.FileExists(t, filepath.Join(destdir, ".github", "workflows", "ci.yml"))
+		assert.NoFileExists(t, filepath.Join(destdir, ".github", "workflows", "ignored.md"))
+
+		release, err := os.ReadFile(filepath.Join(destdir, "scripts", "release"))
+		require.NoError(t, err)
+		assert.Equal(t, "#!/bin/sh\necho release\n", string(release)) // no header since file type is unknown
+		info, err := os.Stat(filepath.Join(destdir, "scripts", "release"))
+		require.NoError(t, err)
+		assert.Equal(t, cfs.RwxRxRxRx, info.Mode().Perm())
+	})
+
+	t.Run("success_conditions", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "Dockerfile"), []byte("FROM scratch\n"), cfs.RwRR))
+
+		// Act
+		err := run(destdir, craft.Configuration{Platform: craft.GitLab})
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(destdir, "Dockerfile"))
+		assert.NoDirExists(t, filepath.Join(destdir, ".github"))
+	})
+
+	for name, handler := range map[string]string{
+		"error_evaluation_field":  `generate: '{{ .Dokcer }}'`,
+		"error_evaluation_result": `remove: '{{ .ProjectName }}'`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			destdir := t.TempDir()
+			templates := fstest.MapFS{
+				"templates/" + craft.ManifestFile: {Data: []byte("handlers:\n  - files: [Dockerfile]\n    " + handler + "\n")},
+				"templates/Dockerfile.tmpl":       {Data: []byte("FROM scratch\n")},
+			}
+
+			// Act
+			_, _, err := generate.Run(ctx, craft.Configuration{},
+				generate.WithDestination(destdir),
+				generate.WithParsers(generate.ParserNoop),
+				generate.WithTemplates("templates", templates))
+
+			// Assert
+			assert.ErrorContains(t, err, "'Dockerfile': craft.yaml")
+			assert.NoFileExists(t, filepath.Join(destdir, "Dockerfile"))
+		})
+	}
+
+	t.Run("error_invalid", func(t *testing.T) {
+		// Arrange
+		templates := fstest.MapFS{
+			"templates/" + craft.ManifestFile: {Data: []byte("handlers:\n  - files: []\n  - files: [Dockerfile]\n    delimiter: unknown\n  - files: [Dockerfile]\n    generate: '{{ .Docker'\n" +
+				"  - files: [Dockerfile]\n    line_ending: cr\n  - files: [Dockerfile]\n    mode: '0999'\n")},
+		}
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{},
+			generate.WithParsers(generate.ParserNoop),
+			generate.WithTemplates("templates", templates))
+
+		// Assert
+		assert.ErrorContains(t, err, "craft.yaml handler 0: at least one file glob must be provided")
+		assert.ErrorContains(t, err, "craft.yaml handler 1: invalid delimiter 'unknown'")
+		assert.ErrorContains(t, err, "craft.yaml handler 2: parse generate expression")
+		assert.ErrorContains(t, err, "craft.yaml handler 3: invalid line_ending 'cr'")
+		assert.ErrorContains(t, err, "craft.yaml handler 4: invalid mode '0999'")
+	})
+}
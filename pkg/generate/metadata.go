@@ -9,6 +9,8 @@ type Metadata struct {
 	craft.Configuration
 
 	// Languages is a map of languages name with its specificities.
+	//
+	// Go code should prefer Language typed keys to set or retrieve them (e.g. parser.LanguageGolang).
 	Languages map[string]any `json:"-"`
 
 	// ProjectHost represents the host where the project is hosted.
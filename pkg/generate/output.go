@@ -0,0 +1,387 @@
+package generate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+)
+
+// OutputFS represents the destination filesystem where Run writes (or removes) generated files.
+//
+// Like fs.FS, all names are slash separated and relative to the filesystem root,
+// which is the destination directory given with WithDestination.
+//
+// Note that parsers and handlers still inspect the destination directory on OS
+// (e.g. to detect a go.mod or whether a file is generated), only Run writes and removals go through OutputFS.
+type OutputFS interface {
+	fs.FS
+
+	// MkdirAll creates the directory name with all its parents (see os.MkdirAll).
+	MkdirAll(name string, perm fs.FileMode) error
+
+	// RemoveAll removes name and all children it contains (see os.RemoveAll).
+	RemoveAll(name string) error
+
+	// WriteFile writes data into name with perm rights, even when name already exists (see os.WriteFile).
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// SymlinkFS is an OutputFS supporting symbolic links (see HandlerResult Symlink).
+//
+// OSOutput and MemoryOutput implement it, archives (see TarOutput and ZipOutput) don't.
+type SymlinkFS interface {
+	OutputFS
+
+	// Readlink returns the target of the symbolic link name (see os.Readlink).
+	Readlink(name string) (string, error)
+
+	// Symlink creates name as a symbolic link to target (see os.Symlink).
+	Symlink(target, name string) error
+}
+
+// ArchiveFS is an OutputFS keeping all files in memory until it's closed,
+// in which case they're written as an archive (see TarOutput and ZipOutput).
+type ArchiveFS interface {
+	OutputFS
+	io.Closer
+}
+
+// OSOutput returns the OutputFS writing into dir on OS.
+//
+// It's the default OutputFS of Run with dir being the destination directory.
+func OSOutput(dir string) OutputFS {
+	return osOutput{FS: os.DirFS(dir), dir: dir}
+}
+
+type osOutput struct {
+	fs.FS
+	dir string
+}
+
+var _ SymlinkFS = osOutput{} // ensure interface is implemented
+
+// path returns the OS path of name, checking that name is a valid fs.FS name.
+func (o osOutput) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(o.dir, filepath.FromSlash(name)), nil
+}
+
+// MkdirAll creates the directory name with all its parents in OS.
+func (o osOutput) MkdirAll(name string, perm fs.FileMode) error {
+	dir, err := o.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, perm) //nolint:wrapcheck
+}
+
+// RemoveAll removes name and all children it contains from OS.
+func (o osOutput) RemoveAll(name string) error {
+	dest, err := o.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dest) //nolint:wrapcheck
+}
+
+// WriteFile writes data into name on OS and refreshes its rights in case it already existed.
+func (o osOutput) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	dest, err := o.path("write", name)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, perm); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// force refresh rights since WriteFile doesn't do it
+	// in case the target file already exists
+	return os.Chmod(dest, perm) //nolint:wrapcheck
+}
+
+// Readlink returns the target of the symbolic link name on OS.
+func (o osOutput) Readlink(name string) (string, error) {
+	link, err := o.path("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(link) //nolint:wrapcheck
+}
+
+// Symlink creates name as a symbolic link to target on OS.
+func (o osOutput) Symlink(target, name string) error {
+	link, err := o.path("symlink", name)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, link) //nolint:wrapcheck
+}
+
+// MemoryOutput returns an empty in memory OutputFS.
+//
+// Generated files can then be read with fs functions (fs.ReadFile, fs.WalkDir, etc.).
+func MemoryOutput() OutputFS {
+	return &memoryOutput{files: map[string]memoryFile{}}
+}
+
+type memoryOutput struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile // directories are kept with fs.ModeDir in their mode and symbolic links with fs.ModeSymlink
+}
+
+var _ SymlinkFS = (*memoryOutput)(nil) // ensure interface is implemented
+
+// memoryFile is a file kept in memory, data being the target of symbolic links.
+type memoryFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// Open opens the file (or directory) name.
+func (m *memoryOutput) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[name]
+	if ok && !file.mode.IsDir() {
+		return &memoryOpenFile{Reader: bytes.NewReader(file.data), info: newMemoryInfo(name, file)}, nil
+	}
+
+	// look for directory children (directories can be implicit when only files were written)
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	children := map[string]memoryFile{}
+	for key, child := range m.files {
+		rel, found := strings.CutPrefix(key, prefix)
+		if !found || rel == "" {
+			continue
+		}
+		if base, _, nested := strings.Cut(rel, "/"); nested {
+			children[base] = memoryFile{mode: fs.ModeDir | cfs.RwxRxRxRx}
+		} else if _, exists := children[base]; !exists {
+			children[base] = child
+		}
+	}
+	if !ok && len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !ok {
+		file = memoryFile{mode: fs.ModeDir | cfs.RwxRxRxRx}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for base, child := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(newMemoryInfo(base, child)))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return &dirFile{entries: entries, info: newMemoryInfo(name, file)}, nil
+}
+
+// MkdirAll creates the directory name with all its parents in memory.
+func (m *memoryOutput) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if file, ok := m.files[dir]; ok && !file.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+	}
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if _, ok := m.files[dir]; !ok {
+			m.files[dir] = memoryFile{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+		}
+	}
+	return nil
+}
+
+// RemoveAll removes name and all children it contains from memory.
+func (m *memoryOutput) RemoveAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range m.files {
+		if name == "." || key == name || strings.HasPrefix(key, name+"/") {
+			delete(m.files, key)
+		}
+	}
+	return nil
+}
+
+// WriteFile writes data into name in memory.
+func (m *memoryOutput) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if file, ok := m.files[name]; ok && file.mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+	m.files[name] = memoryFile{data: slices.Clone(data), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// Readlink returns the target of the symbolic link name in memory.
+func (m *memoryOutput) Readlink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	if file.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return string(file.data), nil
+}
+
+// Symlink creates name as a symbolic link to target in memory.
+//
+// Symbolic links aren't followed when opening them, they're read as regular files with their target as content.
+func (m *memoryOutput) Symlink(target, name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.files[name]; ok {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrExist}
+	}
+	m.files[name] = memoryFile{data: []byte(target), mode: fs.ModeSymlink | fs.ModePerm, modTime: time.Now()}
+	return nil
+}
+
+// TarOutput returns an in memory ArchiveFS writing all its files as a tar archive into w once closed.
+func TarOutput(w io.Writer) ArchiveFS {
+	return &archiveOutput{OutputFS: MemoryOutput(), write: func(fsys fs.FS) error {
+		writer := tar.NewWriter(w)
+		if err := writer.AddFS(fsys); err != nil {
+			return fmt.Errorf("add files: %w", err)
+		}
+		return writer.Close() //nolint:wrapcheck
+	}}
+}
+
+// ZipOutput returns an in memory ArchiveFS writing all its files as a zip archive into w once closed.
+func ZipOutput(w io.Writer) ArchiveFS {
+	return &archiveOutput{OutputFS: MemoryOutput(), write: func(fsys fs.FS) error {
+		writer := zip.NewWriter(w)
+		if err := writer.AddFS(fsys); err != nil {
+			return fmt.Errorf("add files: %w", err)
+		}
+		return writer.Close() //nolint:wrapcheck
+	}}
+}
+
+type archiveOutput struct {
+	OutputFS
+	write func(fsys fs.FS) error
+}
+
+var _ ArchiveFS = (*archiveOutput)(nil) // ensure interface is implemented
+
+// Close writes the archive with all files written in memory.
+func (a *archiveOutput) Close() error {
+	return a.write(a.OutputFS)
+}
+
+// memoryInfo is the fs.FileInfo of a memoryFile.
+type memoryInfo struct {
+	name string
+	file memoryFile
+}
+
+var _ fs.FileInfo = memoryInfo{} // ensure interface is implemented
+
+func newMemoryInfo(name string, file memoryFile) memoryInfo {
+	return memoryInfo{name: path.Base(name), file: file}
+}
+
+func (i memoryInfo) Name() string       { return i.name }
+func (i memoryInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i memoryInfo) Mode() fs.FileMode  { return i.file.mode }
+func (i memoryInfo) ModTime() time.Time { return i.file.modTime }
+func (i memoryInfo) IsDir() bool        { return i.file.mode.IsDir() }
+func (memoryInfo) Sys() any             { return nil }
+
+// memoryOpenFile is an opened regular memoryFile.
+type memoryOpenFile struct {
+	*bytes.Reader
+	info memoryInfo
+}
+
+var _ fs.File = (*memoryOpenFile)(nil) // ensure interface is implemented
+
+func (f *memoryOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (*memoryOpenFile) Close() error                 { return nil }
+
+// dirFile is an opened directory with already known entries.
+type dirFile struct {
+	entries []fs.DirEntry
+	info    fs.FileInfo
+	offset  int
+}
+
+var _ fs.ReadDirFile = (*dirFile)(nil) // ensure interface is implemented
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (*dirFile) Close() error                 { return nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+// ReadDir reads the directory entries following fs.ReadDirFile contract.
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
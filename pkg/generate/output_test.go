@@ -0,0 +1,142 @@
+package generate_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+func TestOSOutput(t *testing.T) {
+	t.Run("error_invalid_path", func(t *testing.T) {
+		// Arrange
+		out := generate.OSOutput(t.TempDir())
+
+		// Act
+		err := out.WriteFile("../file.txt", []byte("content"), cfs.RwRR)
+
+		// Assert
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		out := generate.OSOutput(destdir)
+
+		// Act
+		require.NoError(t, out.MkdirAll("dir", cfs.RwxRxRxRx))
+		require.NoError(t, out.WriteFile("dir/script.sh", []byte("echo"), cfs.RwxRxRxRx))
+		require.NoError(t, out.WriteFile("file.txt", []byte("content"), cfs.RwRR))
+		require.NoError(t, out.RemoveAll("file.txt"))
+
+		// Assert
+		info, err := os.Stat(filepath.Join(destdir, "dir", "script.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, cfs.RwxRxRxRx, info.Mode().Perm())
+		assert.NoFileExists(t, filepath.Join(destdir, "file.txt"))
+		assert.NoError(t, fstest.TestFS(out, "dir/script.sh"))
+	})
+}
+
+func TestMemoryOutput(t *testing.T) {
+	t.Run("error_write_directory", func(t *testing.T) {
+		// Arrange
+		out := generate.MemoryOutput()
+		require.NoError(t, out.MkdirAll("dir", cfs.RwxRxRxRx))
+
+		// Act
+		err := out.WriteFile("dir", []byte("content"), cfs.RwRR)
+
+		// Assert
+		assert.ErrorContains(t, err, "is a directory")
+	})
+
+	t.Run("error_mkdir_file", func(t *testing.T) {
+		// Arrange
+		out := generate.MemoryOutput()
+		require.NoError(t, out.WriteFile("file", []byte("content"), cfs.RwRR))
+
+		// Act
+		err := out.MkdirAll("file/dir", cfs.RwxRxRxRx)
+
+		// Assert
+		assert.ErrorContains(t, err, "not a directory")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		out := generate.MemoryOutput()
+
+		// Act
+		require.NoError(t, out.MkdirAll("empty", cfs.RwxRxRxRx))
+		require.NoError(t, out.WriteFile("dir/sub/file.txt", []byte("content"), cfs.RwRR))
+		require.NoError(t, out.WriteFile("file.txt", []byte("content"), cfs.RwRR))
+		require.NoError(t, out.WriteFile("removed/file.txt", []byte("content"), cfs.RwRR))
+		require.NoError(t, out.RemoveAll("removed"))
+
+		// Assert
+		require.NoError(t, fstest.TestFS(out, "dir/sub/file.txt", "empty", "file.txt"))
+		_, err := fs.Stat(out, "removed")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		bytes, err := fs.ReadFile(out, "dir/sub/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "content", string(bytes))
+	})
+}
+
+func TestTarOutput(t *testing.T) {
+	// Arrange
+	var buffer bytes.Buffer
+	out := generate.TarOutput(&buffer)
+	require.NoError(t, out.WriteFile("dir/file.txt", []byte("content"), cfs.RwRR))
+
+	// Act
+	err := out.Close()
+
+	// Assert
+	require.NoError(t, err)
+	files := map[string]string{}
+	reader := tar.NewReader(&buffer)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		bytes, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		files[header.Name] = string(bytes)
+	}
+	assert.Equal(t, "content", files["dir/file.txt"])
+}
+
+func TestZipOutput(t *testing.T) {
+	// Arrange
+	var buffer bytes.Buffer
+	out := generate.ZipOutput(&buffer)
+	require.NoError(t, out.WriteFile("dir/file.txt", []byte("content"), cfs.RwRR))
+
+	// Act
+	err := out.Close()
+
+	// Assert
+	require.NoError(t, err)
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.NoError(t, err)
+	bytes, err := fs.ReadFile(reader, "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(bytes))
+}
@@ -6,23 +6,46 @@ import (
 	"github.com/kilianpaquier/craft/pkg/generate"
 )
 
-// Defaults returns the full slice of handlers implemented in parser package.
-func Defaults(parsers ...generate.Parser) []generate.Parser {
+// Defaults returns the full slice of parsers implemented in parser package
+// with the metadata fields they consume and provide.
+//
+// Custom parsers are given after Node and before Helm, as such undeclared ones (see generate.ParserNode)
+// run after all default parsers except Helm. Declared ones run wherever their dependencies allow them to.
+func Defaults(parsers ...generate.ParserNode) []generate.ParserNode {
 	return slices.Concat(
-		[]generate.Parser{
-			// parse git repository first
-			Git,
-
-			License, // parse license configuration in configuration and generate it
-			Golang,  // parse go.mod
-			Node,    // parse package.json
+		[]generate.ParserNode{
+			{
+				Parser:   Git,
+				Provides: []string{"Platform", "ProjectHost", "ProjectName", "ProjectPath"},
+			},
+			{
+				// parse license configuration in configuration and generate it,
+				// declaring neither consumed nor provided fields to run alone after Git and before Golang and Node
+				// (as such LICENSE project name is the git one, not overridden by go.mod or package.json)
+				Parser: License,
+			},
+			{
+				Parser: Golang, // parse go.mod (overriding git project properties)
+				Provides: []string{
+					"Binaries", "Clis", "Crons", "Jobs", LanguageGolang.Field(), LanguageHugo.Field(),
+					"Platform", "ProjectHost", "ProjectName", "ProjectPath", "Workers",
+				},
+			},
+			{
+				Parser:   Node, // parse package.json (overriding other project name)
+				Provides: []string{"Binaries", LanguageNode.Field(), "ProjectName"},
+			},
 		},
 
 		// append custom parsers
 		parsers,
 
-		[]generate.Parser{
-			Helm, // parse helm configuration and overrides (must be call last)
+		[]generate.ParserNode{
+			{
+				Parser:   Helm, // parse helm configuration and overrides (with all other parsers metadata)
+				Consumes: []string{"*"},
+				Provides: []string{LanguageHelm.Field()},
+			},
 		},
 	)
 }
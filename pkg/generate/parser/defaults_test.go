@@ -2,10 +2,20 @@ package parser_test
 
 import (
 	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/jarcoal/httpmock"
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/kilianpaquier/craft/pkg/craft"
 	"github.com/kilianpaquier/craft/pkg/generate"
 	"github.com/kilianpaquier/craft/pkg/generate/parser"
 )
@@ -13,9 +23,46 @@ import (
 func TestDefaultParsers(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		// Act
-		parsers := parser.Defaults(func(_ context.Context, _ string, _ *generate.Metadata) error { return nil })
+		parsers := parser.Defaults(generate.ParserNode{Parser: func(_ context.Context, _ string, _ *generate.Metadata) error { return nil }})
 
 		// Assert
 		assert.Len(t, parsers, 6) // can't compare functions between them
 	})
+
+	t.Run("success_license_git_project_name", func(t *testing.T) {
+		// Arrange
+		httpClient := cleanhttp.DefaultClient()
+		httpmock.ActivateNonDefault(httpClient)
+		t.Cleanup(httpmock.DeactivateAndReset)
+		ctx := context.WithValue(context.Background(), parser.HTTPClientKey, httpClient)
+
+		var project string
+		httpmock.RegisterResponder(http.MethodGet, parser.GitLabURL+"/templates/licenses/mit",
+			func(req *http.Request) (*http.Response, error) {
+				project = req.URL.Query().Get("project")
+				return httpmock.NewStringResponse(http.StatusOK, `{"content":"license"}`), nil
+			})
+
+		destdir := t.TempDir()
+		for _, args := range [][]string{{"init", "--quiet"}, {"remote", "add", "origin", "https://github.com/kilianpaquier/git-name.git"}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = destdir
+			require.NoError(t, cmd.Run())
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, craft.PackageJSON), []byte(`{"name":"node-name","packageManager":"bun@1.1.6","private":true}`), cfs.RwRR))
+
+		license := "mit"
+		config := craft.Configuration{License: &license, Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}}, NoChart: true}
+
+		// Act
+		_, _, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(func(string, string, string) (generate.HandlerResult, bool) { return generate.HandlerResult{}, false }),
+			generate.WithParserNodes(parser.Defaults()...),
+			generate.WithTemplates(".", fstest.MapFS{}))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "git-name", project)
+	})
 }
@@ -7,14 +7,14 @@ Example:
 
 	// one specific parser
 	func main() {
-		handlers, err := parser.Git(ctx, "path/to/dir", metadata) // metadata is updated in the process
+		err := parser.Git(ctx, "path/to/dir", metadata) // metadata is updated in the process
 		// handle err
 	}
 
 	// as a whole
 	func main() {
-		for _, p := range parser.Defaults() {
-			handlers, err := p(ctx, "path/to/dir", metadata) // metadata is updated in the process
+		for _, node := range parser.Defaults() {
+			err := node.Parser(ctx, "path/to/dir", metadata) // metadata is updated in the process
 			// handle err
 		}
 	}
@@ -23,7 +23,7 @@ Example:
 	func main() {
 		// config (craft.Configuration) is updated during the process
 		// and returned updated at the end
-		config, err := generate.Run(ctx, config, generate.WithParsers(parser.Defaults()...))
+		config, report, err := generate.Run(ctx, config, generate.WithParserNodes(parser.Defaults()...))
 		// handle err
 	}
 */
@@ -27,6 +27,14 @@ var (
 
 var versionRegexp = regexp.MustCompile("^v[0-9]+$")
 
+const (
+	// LanguageGolang is the language set by Golang parser with go.mod statements.
+	LanguageGolang generate.Language[Gomod] = "golang"
+
+	// LanguageHugo is the language set by Golang parser when the project is an hugo website or theme.
+	LanguageHugo generate.Language[struct{}] = "hugo"
+)
+
 // Gomod represents the parsed struct for go.mod file
 type Gomod struct {
 	LangVersion string
@@ -63,7 +71,7 @@ func Golang(ctx context.Context, destdir string, metadata *generate.Metadata) er
 	}
 
 	generate.GetLogger(ctx).Infof("golang detected, file '%s' is present and valid", craft.Gomod)
-	metadata.Languages["golang"] = statements
+	LanguageGolang.Set(metadata, statements)
 
 	entries, err := os.ReadDir(gocmd)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
@@ -101,7 +109,7 @@ func isHugo(ctx context.Context, destdir string, metadata *generate.Metadata) bo
 
 	if len(configs) > 0 || len(themes) > 0 {
 		generate.GetLogger(ctx).Infof("hugo detected, a hugo configuration file or hugo theme file is present")
-		metadata.Languages["hugo"] = nil
+		LanguageHugo.Set(metadata, struct{}{})
 		return true
 	}
 	return false
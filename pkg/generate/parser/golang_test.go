@@ -127,7 +127,7 @@ func TestGolang(t *testing.T) {
 			Configuration: craft.Configuration{
 				Platform: craft.GitHub,
 			},
-			Languages:   map[string]any{"hugo": nil},
+			Languages:   map[string]any{"hugo": struct{}{}},
 			ProjectHost: "github.com",
 			ProjectName: "craft",
 			ProjectPath: "kilianpaquier/craft",
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
-	"os"
 	"path/filepath"
 
 	"dario.cat/mergo"
@@ -15,11 +14,14 @@ import (
 	"github.com/kilianpaquier/craft/pkg/generate"
 )
 
+// LanguageHelm is the language set by Helm parser with chart values (craft configuration merged with chart overrides).
+const LanguageHelm generate.Language[map[string]any] = "helm"
+
 // Helm parses helm partin destdir repository.
 func Helm(ctx context.Context, destdir string, metadata *generate.Metadata) error {
 	chartdir := filepath.Join(destdir, "chart")
 	if metadata.NoChart {
-		if err := os.RemoveAll(chartdir); err != nil {
+		if err := generate.RemoveAll(ctx, chartdir); err != nil {
 			return fmt.Errorf("remove chart dir: %w", err)
 		}
 		return nil
@@ -42,7 +44,7 @@ func Helm(ctx context.Context, destdir string, metadata *generate.Metadata) erro
 		return fmt.Errorf("merge helm chart overrides with craft configuration: %w", err)
 	}
 
-	metadata.Languages["helm"] = chart
+	LanguageHelm.Set(metadata, chart)
 	return nil
 }
 
@@ -92,7 +92,7 @@ func TestHelm(t *testing.T) {
 
 		// Assert
 		require.NoError(t, err)
-		values, ok := config.Languages["helm"]
+		values, ok := parser.LanguageHelm.Get(config)
 		require.True(t, ok)
 		assert.Equal(t, expected, values)
 	})
@@ -2,9 +2,7 @@ package parser
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -29,7 +27,7 @@ const (
 func License(ctx context.Context, destdir string, metadata *generate.Metadata) error {
 	dest := filepath.Join(destdir, craft.License)
 	if metadata.License == nil {
-		if err := os.Remove(dest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		if err := generate.Remove(ctx, dest); err != nil {
 			return fmt.Errorf("remove '%s': %w", craft.License, err)
 		}
 		return nil
@@ -67,7 +65,7 @@ func License(ctx context.Context, destdir string, metadata *generate.Metadata) e
 	}
 
 	// write license template
-	if err := os.WriteFile(dest, []byte(license.Content), cfs.RwRR); err != nil {
+	if err := generate.WriteFile(ctx, dest, []byte(license.Content), cfs.RwRR); err != nil {
 		return fmt.Errorf("write license file: %w", err)
 	}
 	return nil
@@ -27,6 +27,9 @@ var (
 
 var packageManagerRegexp = regexp.MustCompile(`^(npm|pnpm|yarn|bun)@\d+\.\d+\.\d+(-.+)?$`)
 
+// LanguageNode is the language set by Node parser with package.json content.
+const LanguageNode generate.Language[PackageJSON] = "node"
+
 // PackageJSON represents the node package json descriptor.
 type PackageJSON struct {
 	Author         *string  `json:"author,omitempty"`
@@ -81,7 +84,7 @@ func Node(ctx context.Context, destdir string, metadata *generate.Metadata) erro
 	}
 	generate.GetLogger(ctx).Infof("node detected, a '%s' is present and valid", craft.PackageJSON)
 
-	metadata.Languages["node"] = pkg
+	LanguageNode.Set(metadata, pkg)
 	metadata.ProjectName = pkg.Name
 	if pkg.Main != nil {
 		metadata.Binaries++
@@ -0,0 +1,334 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrParserConflict is the error returned (wrapped) when parsers run concurrently set the same Metadata field.
+	//
+	// It usually means that one of them sets a field without declaring it in its Provides.
+	ErrParserConflict = errors.New("parsers conflict")
+
+	// ErrParserCycle is the error returned (wrapped) when parsers dependencies (see ParserNode) are cyclic.
+	ErrParserCycle = errors.New("parsers dependency cycle")
+)
+
+// ParserNode is a Parser with the Metadata fields it consumes (reads) and provides (sets).
+//
+// It allows Run to order parsers as a dependency graph instead of a fixed ordering:
+//   - a parser consuming a field runs after all parsers providing it,
+//   - parsers providing the same field run in given order (the last one overriding previous values),
+//   - other parsers are independent and run concurrently.
+//
+// Fields are Metadata field names (craft.Configuration ones included, e.g. "ProjectName" or "NoChart"),
+// optionally followed by a key for map fields (e.g. "Languages.node").
+// Consumes can also be "*" to consume all fields provided by other parsers (e.g. to run after all of them).
+//
+// A ParserNode declaring neither Consumes nor Provides runs alone,
+// after all parsers given before it and before all parsers given after it (see WithParsers).
+type ParserNode struct {
+	// Name is the parser name used in logs, errors and Report provenance.
+	//
+	// By default, it's the Parser function name (e.g. "parser.Golang").
+	Name string
+
+	// Parser is the parser function.
+	Parser Parser
+
+	// Consumes is the slice of Metadata fields read by Parser.
+	Consumes []string
+
+	// Provides is the slice of Metadata fields set by Parser.
+	Provides []string
+}
+
+// declared returns truthy in case the node declares either its consumed or provided fields.
+func (n ParserNode) declared() bool {
+	return len(n.Consumes) > 0 || len(n.Provides) > 0
+}
+
+// validate checks that all consumed and provided fields exist in Metadata.
+func (n ParserNode) validate() error {
+	errs := make([]error, 0, len(n.Consumes)+len(n.Provides))
+	for _, field := range n.Consumes {
+		if field != "*" && !isMetadataField(field) {
+			errs = append(errs, fmt.Errorf("parser '%s': unknown consumed field '%s'", n.Name, field))
+		}
+	}
+	for _, field := range n.Provides {
+		if !isMetadataField(field) {
+			errs = append(errs, fmt.Errorf("parser '%s': unknown provided field '%s'", n.Name, field))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isMetadataField returns truthy in case field is a Metadata field name,
+// optionally followed by a key when it's a map (e.g. "Languages.node").
+func isMetadataField(field string) bool {
+	name, key, keyed := strings.Cut(field, ".")
+	for _, f := range metadataFields() {
+		if f.Name == name {
+			return !keyed || (f.Type.Kind() == reflect.Map && key != "")
+		}
+	}
+	return false
+}
+
+// metadataFields returns all exported Metadata fields, craft.Configuration ones included.
+func metadataFields() []reflect.StructField {
+	fields := reflect.VisibleFields(reflect.TypeOf(Metadata{}))
+	return slices.DeleteFunc(fields, func(field reflect.StructField) bool {
+		return field.Anonymous || !field.IsExported()
+	})
+}
+
+// parserLevels validates input parsers and orders them into levels,
+// all parsers of a level only depending on parsers of previous levels.
+//
+// Parsers of a level are kept in given order.
+func parserLevels(nodes []ParserNode) ([][]ParserNode, error) {
+	errs := make([]error, 0, len(nodes))
+	for _, node := range nodes {
+		errs = append(errs, node.validate())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	done := make([]bool, len(nodes))
+	var levels [][]ParserNode
+	for count := 0; count < len(nodes); {
+		var indexes []int
+		for j := range nodes {
+			if done[j] {
+				continue
+			}
+			ready := true
+			for i := range nodes {
+				if i != j && !done[i] && runsBefore(nodes, i, j) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				indexes = append(indexes, j)
+			}
+		}
+
+		if len(indexes) == 0 {
+			var names []string
+			for i, node := range nodes {
+				if !done[i] {
+					names = append(names, node.Name)
+				}
+			}
+			return nil, fmt.Errorf("%w between '%s'", ErrParserCycle, strings.Join(names, "', '"))
+		}
+
+		level := make([]ParserNode, 0, len(indexes))
+		for _, i := range indexes {
+			done[i] = true
+			level = append(level, nodes[i])
+		}
+		levels = append(levels, level)
+		count += len(indexes)
+	}
+	return levels, nil
+}
+
+// runsBefore returns truthy in case nodes[i] must run before nodes[j].
+func runsBefore(nodes []ParserNode, i, j int) bool {
+	before, after := nodes[i], nodes[j]
+	if i < j && (!before.declared() || !after.declared() || overlaps(before.Provides, after.Provides)) {
+		return true
+	}
+	if i > j && slices.Contains(before.Consumes, "*") && slices.Contains(after.Consumes, "*") {
+		return false // parsers consuming all fields run in given order
+	}
+	return overlaps(after.Consumes, before.Provides)
+}
+
+// overlaps returns truthy in case one of fields matches one of others
+// (the same field, a map field and one of its keys or "*").
+func overlaps(fields, others []string) bool {
+	for _, field := range fields {
+		for _, other := range others {
+			if field == "*" || field == other ||
+				strings.HasPrefix(other, field+".") || strings.HasPrefix(field, other+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runParsers runs input levels of parsers one after the other.
+//
+// Parsers of the same level are run concurrently, each one with its own copy of metadata.
+// Once they're all done, the fields they've set are merged into metadata.
+//
+// It returns, for each field set by parsers, the name of the parser having set it last.
+func runParsers(ctx context.Context, destdir string, levels [][]ParserNode, metadata *Metadata) (map[string]string, error) {
+	log := GetLogger(ctx)
+	provenance := map[string]string{}
+	for _, level := range levels {
+		if err := ctx.Err(); err != nil {
+			return provenance, err //nolint:wrapcheck
+		}
+
+		copies := make([]Metadata, len(level))
+		errs := make([]error, len(level))
+		var wg sync.WaitGroup
+		for i, node := range level {
+			copies[i] = cloneMetadata(*metadata)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = node.Parser(ctx, destdir, &copies[i])
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return provenance, err
+		}
+
+		setters := map[string]string{}
+		changes := make([][]string, len(level))
+		for i, node := range level {
+			changes[i] = changedFields(*metadata, copies[i])
+			for _, field := range changes[i] {
+				if setter, ok := setters[field]; ok {
+					return provenance, fmt.Errorf("%w: '%s' set by both '%s' and '%s'", ErrParserConflict, field, setter, node.Name)
+				}
+				setters[field] = node.Name
+				if node.declared() && !overlaps(node.Provides, []string{field}) {
+					log.Warnf("parser '%s' sets '%s' without providing it", node.Name, field)
+				}
+			}
+		}
+
+		for i, node := range level {
+			for _, field := range changes[i] {
+				log.Debugf("parser '%s' sets '%s'", node.Name, field)
+				setField(metadata, copies[i], field)
+				provenance[field] = node.Name
+			}
+		}
+	}
+	return provenance, nil
+}
+
+// changedFields returns all fields (see ParserNode) different between before and after.
+//
+// Map fields are compared key by key.
+func changedFields(before, after Metadata) []string {
+	var fields []string
+	beforeValue, afterValue := reflect.ValueOf(before), reflect.ValueOf(after)
+	for _, field := range metadataFields() {
+		previous, current := beforeValue.FieldByIndex(field.Index), afterValue.FieldByIndex(field.Index)
+		if field.Type.Kind() != reflect.Map {
+			if !reflect.DeepEqual(previous.Interface(), current.Interface()) {
+				fields = append(fields, field.Name)
+			}
+			continue
+		}
+
+		var keys []string
+		for _, value := range []reflect.Value{previous, current} {
+			for _, key := range value.MapKeys() {
+				if !slices.Contains(keys, key.String()) {
+					keys = append(keys, key.String())
+				}
+			}
+		}
+		for _, key := range keys {
+			k := reflect.ValueOf(key).Convert(field.Type.Key())
+			old, value := previous.MapIndex(k), current.MapIndex(k)
+			if old.IsValid() != value.IsValid() || (old.IsValid() && !reflect.DeepEqual(old.Interface(), value.Interface())) {
+				fields = append(fields, field.Name+"."+key)
+			}
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// setField sets field (see ParserNode) of metadata with the one of src.
+//
+// A map key missing in src is removed from metadata.
+func setField(metadata *Metadata, src Metadata, field string) {
+	name, key, keyed := strings.Cut(field, ".")
+	dst, value := reflect.ValueOf(metadata).Elem().FieldByName(name), reflect.ValueOf(src).FieldByName(name)
+	if !keyed {
+		dst.Set(value)
+		return
+	}
+
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMap(dst.Type()))
+	}
+	k := reflect.ValueOf(key).Convert(dst.Type().Key())
+	dst.SetMapIndex(k, value.MapIndex(k)) // an invalid value (missing key) removes the key
+}
+
+// cloneMetadata returns a deep copy of metadata.
+func cloneMetadata(metadata Metadata) Metadata {
+	var clone Metadata
+	reflect.ValueOf(&clone).Elem().Set(deepCopy(reflect.ValueOf(metadata)))
+	return clone
+}
+
+// deepCopy returns a deep copy of value.
+//
+// Unexported structs fields can't be set with reflection and as such are shallow copied.
+func deepCopy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+	default:
+	}
+
+	switch value.Kind() {
+	case reflect.Interface:
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(deepCopy(value.Elem()))
+		return clone
+	case reflect.Map:
+		clone := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			clone.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return clone
+	case reflect.Pointer:
+		clone := reflect.New(value.Type().Elem())
+		clone.Elem().Set(deepCopy(value.Elem()))
+		return clone
+	case reflect.Slice:
+		clone := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := range value.Len() {
+			clone.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(value)
+		for i := range value.NumField() {
+			if clone.Field(i).CanSet() {
+				clone.Field(i).Set(deepCopy(value.Field(i)))
+			}
+		}
+		return clone
+	default:
+		return value
+	}
+}
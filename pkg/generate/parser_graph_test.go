@@ -0,0 +1,181 @@
+package generate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+func TestRun_ParserNodes(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(nodes ...generate.ParserNode) (generate.Report, error) {
+		_, report, err := generate.Run(ctx, craft.Configuration{},
+			generate.WithDestination(t.TempDir()),
+			generate.WithDryRun(),
+			generate.WithHandlers(generate.HandlerNoop),
+			generate.WithParserNodes(nodes...))
+		return report, err
+	}
+
+	t.Run("success_dependencies_order", func(t *testing.T) {
+		// Arrange
+		var seen []string
+		nodes := []generate.ParserNode{
+			{
+				Name:     "helm",
+				Consumes: []string{"*"},
+				Provides: []string{"Languages.helm"},
+				Parser: func(_ context.Context, _ string, metadata *generate.Metadata) error {
+					seen = append(seen, metadata.ProjectName)
+					metadata.Languages["helm"] = nil
+					return nil
+				},
+			},
+			{
+				Name:     "custom",
+				Consumes: []string{"Languages.node"},
+				Provides: []string{"ProjectName"},
+				Parser: func(_ context.Context, _ string, metadata *generate.Metadata) error {
+					metadata.ProjectName = metadata.Languages["node"].(string) + "-custom"
+					return nil
+				},
+			},
+			{
+				Name:     "node",
+				Provides: []string{"Languages.node"},
+				Parser: func(_ context.Context, _ string, metadata *generate.Metadata) error {
+					metadata.Languages["node"] = "node"
+					return nil
+				},
+			},
+		}
+
+		// Act
+		report, err := run(nodes...)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"node-custom"}, seen)
+		assert.Equal(t, map[string]string{
+			"Languages.helm": "helm",
+			"Languages.node": "node",
+			"ProjectName":    "custom",
+		}, report.Provenance)
+	})
+
+	t.Run("success_concurrent", func(t *testing.T) {
+		// Arrange
+		first, second := make(chan struct{}), make(chan struct{})
+		wait := func(start, other chan struct{}) generate.Parser {
+			return func(context.Context, string, *generate.Metadata) error {
+				close(start)
+				select {
+				case <-other:
+					return nil
+				case <-time.After(5 * time.Second):
+					return errors.New("parsers not run concurrently")
+				}
+			}
+		}
+		nodes := []generate.ParserNode{
+			{Name: "first", Parser: wait(first, second), Provides: []string{"Crons"}},
+			{Name: "second", Parser: wait(second, first), Provides: []string{"Jobs"}},
+		}
+
+		// Act
+		_, err := run(nodes...)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("success_undeclared_in_order", func(t *testing.T) {
+		// Arrange
+		var names []string
+		parser := func(name string) generate.Parser {
+			return func(_ context.Context, _ string, metadata *generate.Metadata) error {
+				names = append(names, name)
+				metadata.ProjectName = name
+				return nil
+			}
+		}
+
+		// Act
+		_, report, err := generate.Run(ctx, craft.Configuration{},
+			generate.WithDestination(t.TempDir()),
+			generate.WithDryRun(),
+			generate.WithHandlers(generate.HandlerNoop),
+			generate.WithParsers(parser("a"), parser("b"), parser("c")))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, names)
+		assert.Contains(t, report.Provenance["ProjectName"], "generate_test.TestRun_ParserNodes")
+	})
+
+	t.Run("error_conflict", func(t *testing.T) {
+		// Arrange
+		nodes := []generate.ParserNode{
+			{
+				Name:     "first",
+				Provides: []string{"Crons"},
+				Parser: func(_ context.Context, _ string, metadata *generate.Metadata) error {
+					metadata.ProjectName = "first"
+					return nil
+				},
+			},
+			{
+				Name:     "second",
+				Provides: []string{"Jobs"},
+				Parser: func(_ context.Context, _ string, metadata *generate.Metadata) error {
+					metadata.ProjectName = "second"
+					return nil
+				},
+			},
+		}
+
+		// Act
+		_, err := run(nodes...)
+
+		// Assert
+		assert.ErrorIs(t, err, generate.ErrParserConflict)
+		assert.ErrorContains(t, err, "'ProjectName' set by both 'first' and 'second'")
+	})
+
+	t.Run("error_cycle", func(t *testing.T) {
+		// Arrange
+		nodes := []generate.ParserNode{
+			{Name: "first", Parser: generate.ParserNoop, Consumes: []string{"Jobs"}, Provides: []string{"Crons"}},
+			{Name: "second", Parser: generate.ParserNoop, Consumes: []string{"Crons"}, Provides: []string{"Jobs"}},
+		}
+
+		// Act
+		_, err := run(nodes...)
+
+		// Assert
+		assert.ErrorIs(t, err, generate.ErrParserCycle)
+		assert.ErrorContains(t, err, "between 'first', 'second'")
+	})
+
+	t.Run("error_unknown_fields", func(t *testing.T) {
+		// Arrange
+		nodes := []generate.ParserNode{
+			{Name: "invalid", Parser: generate.ParserNoop, Consumes: []string{"Unknown"}, Provides: []string{"ProjectName.key"}},
+		}
+
+		// Act
+		_, err := run(nodes...)
+
+		// Assert
+		assert.ErrorContains(t, err, "parser 'invalid': unknown consumed field 'Unknown'")
+		assert.ErrorContains(t, err, "parser 'invalid': unknown provided field 'ProjectName.key'")
+	})
+}
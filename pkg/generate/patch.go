@@ -0,0 +1,262 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+)
+
+// ErrPatchConflict is the error returned (wrapped) when a patch doesn't apply anymore on a rendered template.
+//
+// It happens when the template evolved on the lines concerned by the patch (or their context),
+// in that case the patch must be updated by its owner.
+var ErrPatchConflict = errors.New("patch doesn't apply")
+
+var hunkRegexp = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// hunk represents a unified diff hunk.
+type hunk struct {
+	header string
+	start  int // start line (1-indexed) in original content
+	before []string
+	after  []string
+}
+
+// patchFile applies dest patch file (dest suffixed by craft.PatchExtension) on content in case it exists.
+//
+// Patch files are owned by users to keep their local edits on generated files while still following template updates.
+func patchFile(ctx context.Context, dest string, content []byte) ([]byte, error) {
+	name := filepath.Base(dest) + craft.PatchExtension
+
+	patch, err := os.ReadFile(dest + craft.PatchExtension)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return content, nil
+		}
+		return nil, fmt.Errorf("read patch '%s': %w", name, err)
+	}
+	GetLogger(ctx).Infof("applying patch '%s'", name)
+
+	patched, err := applyPatch(content, patch)
+	if err != nil {
+		return nil, fmt.Errorf("apply patch '%s': %w", name, err)
+	}
+	return patched, nil
+}
+
+// applyPatch applies the input unified diff patch (as generated by diff -u or git diff) to content.
+//
+// Files headers (---, +++, diff --git, etc.) are ignored, only hunks are applied.
+// Hunks are searched around their specified line in case content moved (no fuzz on context lines).
+//
+// Since content doesn't have its generated header yet (see withHeader), header lines are removed from hunks (see withoutHeader),
+// for patches made on generated files (with git diff for instance) to apply.
+func applyPatch(content, patch []byte) ([]byte, error) {
+	parsed, err := parseHunks(string(patch))
+	if err != nil {
+		return nil, err
+	}
+	hunks := make([]hunk, 0, len(parsed))
+	for _, h := range parsed {
+		if h = h.withoutHeader(); len(h.before) > 0 || len(h.after) > 0 {
+			hunks = append(hunks, h)
+		}
+	}
+
+	src := lines(content)
+	result := make([]string, 0, len(src))
+
+	// current is the index in src from which hunks can be applied
+	// and offset the difference between where the last hunk was expected and where it was found
+	var current, offset int
+	for i, h := range hunks {
+		position := h.start - 1
+		if len(h.before) == 0 {
+			position = h.start // pure additions are done after the specified line
+		}
+
+		index, ok := findHunk(src, h.before, current, position+offset)
+		if !ok {
+			return nil, fmt.Errorf("hunk #%d (%s): %w", i+1, h.header, ErrPatchConflict)
+		}
+
+		result = append(result, src[current:index]...)
+		result = append(result, h.after...)
+		current = index + len(h.before)
+		offset = index - position
+	}
+	result = append(result, src[current:]...)
+
+	joined := strings.Join(result, "")
+	return []byte(strings.ReplaceAll(joined, "\n"+noEOL+"\n", "")), nil
+}
+
+// withoutHeader returns h without the generated header line (and the empty line following it) of generated files,
+// in case h is at the beginning of the file (the header being the second line of files starting with a shebang).
+func (h hunk) withoutHeader() hunk {
+	if h.start > 2 {
+		return h
+	}
+	strip := func(lines []string) []string {
+		for i := range min(2, len(lines)) {
+			if !generatedRegexp.MatchString(lines[i]) {
+				continue
+			}
+			end := i + 1
+			if end < len(lines) && lines[end] == "\n" {
+				end++
+			}
+			return slices.Delete(slices.Clone(lines), i, end)
+		}
+		return lines
+	}
+	h.before, h.after = strip(h.before), strip(h.after)
+	return h
+}
+
+// findHunk returns the index in src (starting from start) where old lines can be found,
+// searching first at expected index and then further and further away from it.
+func findHunk(src, old []string, start, expected int) (int, bool) {
+	matches := func(index int) bool {
+		if index < start || index+len(old) > len(src) {
+			return false
+		}
+		for i, line := range old {
+			if src[index+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	for delta := 0; expected-delta >= start || expected+delta <= len(src); delta++ {
+		if matches(expected - delta) {
+			return expected - delta, true
+		}
+		if matches(expected + delta) {
+			return expected + delta, true
+		}
+	}
+	return 0, false
+}
+
+// parseHunks parses all hunks in the input unified diff patch.
+func parseHunks(patch string) ([]hunk, error) {
+	var hunks []hunk
+	var current *hunk
+	var oldCount, newCount int
+	var previous byte // previous line kind in current hunk
+
+	for _, line := range lines([]byte(patch)) {
+		line = strings.TrimSuffix(line, "\n"+noEOL+"\n")
+		text := strings.TrimSuffix(line, "\n")
+
+		// no new line at end of file for the previous line (it can be after the last line of a hunk)
+		if current != nil && strings.HasPrefix(text, "\\") {
+			markNoEOL(current, previous)
+			continue
+		}
+
+		// outside of a hunk, everything is ignored but hunk headers
+		if current == nil || (oldCount == 0 && newCount == 0) {
+			current = nil
+			if !strings.HasPrefix(text, "@@") {
+				continue
+			}
+			h, err := parseHunkHeader(text)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, h.hunk)
+			current = &hunks[len(hunks)-1]
+			oldCount, newCount = h.oldCount, h.newCount
+			continue
+		}
+
+		if text == "" { // some editors trim trailing spaces, an empty line is an empty context line
+			line = " " + line
+		}
+		previous = line[0]
+		switch previous {
+		case ' ':
+			current.before = append(current.before, line[1:])
+			current.after = append(current.after, line[1:])
+			oldCount--
+			newCount--
+		case '-':
+			current.before = append(current.before, line[1:])
+			oldCount--
+		case '+':
+			current.after = append(current.after, line[1:])
+			newCount--
+		default:
+			return nil, fmt.Errorf("invalid line in hunk '%s': %q", current.header, text)
+		}
+		if oldCount < 0 || newCount < 0 {
+			return nil, fmt.Errorf("invalid hunk '%s': too many lines", current.header)
+		}
+	}
+
+	if current != nil && (oldCount > 0 || newCount > 0) {
+		return nil, fmt.Errorf("invalid hunk '%s': unexpected end of patch", current.header)
+	}
+	if len(hunks) == 0 {
+		return nil, errors.New("no hunk found in patch")
+	}
+	return hunks, nil
+}
+
+// markNoEOL marks the last line of hunk before lines or after lines (or both) as a line without new line at the end.
+//
+// It must be called when a "\ No newline at end of file" marker is encountered,
+// the previous line kind giving which lines are concerned.
+func markNoEOL(h *hunk, previous byte) {
+	mark := func(lines []string) {
+		if len(lines) > 0 {
+			lines[len(lines)-1] += noEOL + "\n"
+		}
+	}
+	if previous == ' ' || previous == '-' {
+		mark(h.before)
+	}
+	if previous == ' ' || previous == '+' {
+		mark(h.after)
+	}
+}
+
+type hunkHeader struct {
+	hunk
+	oldCount int
+	newCount int
+}
+
+// parseHunkHeader parses a hunk header of the form "@@ -l,s +l,s @@".
+func parseHunkHeader(text string) (hunkHeader, error) {
+	matches := hunkRegexp.FindStringSubmatch(text)
+	if matches == nil {
+		return hunkHeader{}, fmt.Errorf("invalid hunk header '%s'", text)
+	}
+
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s) // regexp ensures it's a number
+		return n
+	}
+	start, _ := strconv.Atoi(matches[1]) // regexp ensures it's a number
+	return hunkHeader{
+		hunk:     hunk{header: matches[0], start: start},
+		oldCount: count(matches[2]),
+		newCount: count(matches[4]),
+	}, nil
+}
@@ -0,0 +1,136 @@
+package generate //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	content := []byte("first\nsecond\nthird\nfourth\nfifth\nsixth\nseventh\n")
+
+	t.Run("error_no_hunk", func(t *testing.T) {
+		// Act
+		_, err := applyPatch(content, []byte("--- a/file.txt\n+++ b/file.txt\n"))
+
+		// Assert
+		assert.ErrorContains(t, err, "no hunk found in patch")
+	})
+
+	t.Run("error_invalid_header", func(t *testing.T) {
+		// Act
+		_, err := applyPatch(content, []byte("@@ -a,b +c,d @@\n"))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid hunk header '@@ -a,b +c,d @@'")
+	})
+
+	t.Run("error_invalid_line", func(t *testing.T) {
+		// Act
+		_, err := applyPatch(content, []byte("@@ -1,2 +1,2 @@\n first\n*second\n"))
+
+		// Assert
+		assert.ErrorContains(t, err, `invalid line in hunk '@@ -1,2 +1,2 @@': "*second"`)
+	})
+
+	t.Run("error_unexpected_end", func(t *testing.T) {
+		// Act
+		_, err := applyPatch(content, []byte("@@ -1,3 +1,3 @@\n first\n"))
+
+		// Assert
+		assert.ErrorContains(t, err, "unexpected end of patch")
+	})
+
+	t.Run("error_conflict", func(t *testing.T) {
+		// Arrange
+		patch := "@@ -2,3 +2,3 @@\n second\n-3rd\n+third edited\n fourth\n"
+
+		// Act
+		_, err := applyPatch(content, []byte(patch))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrPatchConflict)
+		assert.ErrorContains(t, err, "hunk #1 (@@ -2,3 +2,3 @@)")
+	})
+
+	t.Run("success_git_diff", func(t *testing.T) {
+		// Arrange
+		patch := "diff --git a/file.txt b/file.txt\nindex 3b18e51..b7a3c1e 100644\n--- a/file.txt\n+++ b/file.txt\n" +
+			"@@ -1,3 +1,4 @@\n first\n+inserted\n second\n third\n" +
+			"@@ -5,3 +6,2 @@\n fifth\n-sixth\n seventh\n"
+		expected := "first\ninserted\nsecond\nthird\nfourth\nfifth\nseventh\n"
+
+		// Act
+		patched, err := applyPatch(content, []byte(patch))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(patched))
+	})
+
+	t.Run("success_moved_content", func(t *testing.T) {
+		// Arrange
+		moved := append([]byte("zero\nfirst bis\n"), content...)
+		patch := "@@ -2,3 +2,3 @@\n second\n-third\n+third edited\n fourth\n"
+		expected := "zero\nfirst bis\nfirst\nsecond\nthird edited\nfourth\nfifth\nsixth\nseventh\n"
+
+		// Act
+		patched, err := applyPatch(moved, []byte(patch))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(patched))
+	})
+
+	t.Run("success_addition_only", func(t *testing.T) {
+		// Arrange
+		patch := "@@ -0,0 +1 @@\n+zero\n"
+
+		// Act
+		patched, err := applyPatch(content, []byte(patch))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "zero\n"+string(content), string(patched))
+	})
+
+	t.Run("success_no_newline", func(t *testing.T) {
+		// Arrange
+		patch := "@@ -6,2 +6,3 @@\n sixth\n-seventh\n+seventh\n+eighth\n\\ No newline at end of file\n"
+		expected := "first\nsecond\nthird\nfourth\nfifth\nsixth\nseventh\neighth"
+
+		// Act
+		patched, err := applyPatch(content, []byte(patch))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(patched))
+	})
+
+	t.Run("success_generated_header", func(t *testing.T) {
+		// Arrange
+		patch := "@@ -1,4 +1,4 @@\n-# Code generated by craft v1.0.0 from file.txt.tmpl; DO NOT EDIT.\n+# Code generated by craft v1.1.0 from file.txt.tmpl; DO NOT EDIT.\n \n-first\n+first edited\n second\n"
+		expected := "first edited\nsecond\nthird\nfourth\nfifth\nsixth\nseventh\n"
+
+		// Act
+		patched, err := applyPatch(content, []byte(patch))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(patched))
+	})
+
+	t.Run("success_from_diff", func(t *testing.T) {
+		// Arrange
+		edited := []byte("first\nsecond\nthird\nfourth edited\nfifth\nsixth\nseventh")
+		change := Change{Action: ActionUpdate, Content: edited, Previous: content}
+
+		// Act
+		patched, err := applyPatch(content, []byte(change.Diff("file.txt")))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, string(edited), string(patched))
+	})
+}
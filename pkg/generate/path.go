@@ -0,0 +1,191 @@
+package generate
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// ErrInvalidPath is the error returned (wrapped) when a templated path (see expandFiles) can't be expanded.
+var ErrInvalidPath = errors.New("invalid templated path")
+
+// PathData is the data given to templates whose path is expanded into multiple files
+// with "each" function (e.g. "cmd/{{ each .Clis }}/Dockerfile.tmpl").
+//
+// Metadata is embedded, as such templates still access its properties directly (e.g. .ProjectName).
+type PathData struct {
+	Metadata
+
+	// Name is the name of the current item, i.e. its key for maps or its string representation for slices
+	// (e.g. the CLI name with "{{ each .Clis }}").
+	Name string
+
+	// Item is the current item, i.e. its value for maps or the element itself for slices.
+	Item any
+}
+
+// pathItem is an item of the collection given to "each" in a templated path.
+type pathItem struct {
+	name  string
+	value any
+}
+
+// isTemplated returns truthy in case the input path contains go template expressions.
+func isTemplated(name string) bool {
+	return strings.Contains(name, "{{")
+}
+
+// expandFiles expands files whose destination path (relative to destination directory) contains go template expressions,
+// executed with metadata and always delimited with {{ and }} whatever the file handler delimiters.
+//
+// A path may contain a single "each" call with a map or a slice (e.g. "chart/templates/{{ each .Workers }}-worker.yaml")
+// in which case the file is expanded once per item (map keys being sorted), the item being available in its template (see PathData).
+//
+// Files whose expanded path has an empty element are skipped (e.g. "{{ if .Docker }}Dockerfile{{ end }}").
+func (ro *runOptions) expandFiles(files []file, metadata Metadata) ([]file, error) {
+	expanded := make([]file, 0, len(files))
+	errs := make([]error, 0, len(files))
+	for _, f := range files {
+		rel := lockKey(*ro.destdir, f.dest)
+		if !isTemplated(rel) {
+			expanded = append(expanded, f)
+			continue
+		}
+
+		paths, err := ro.expandPath(rel, metadata)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("'%s': %w", rel, err))
+			continue
+		}
+		for _, p := range paths {
+			expanded = append(expanded, file{data: p.data, dest: filepath.Join(*ro.destdir, filepath.FromSlash(p.rel)), src: f.src})
+		}
+	}
+	return expanded, errors.Join(errs...)
+}
+
+// expandedPath is a path expanded by expandPath with the data to give to its template.
+type expandedPath struct {
+	data any
+	rel  string
+}
+
+// expandPath executes the templated rel path with metadata and returns all resulting paths.
+//
+// See expandFiles for more details.
+func (ro *runOptions) expandPath(rel string, metadata Metadata) ([]expandedPath, error) {
+	var (
+		collection any
+		called     bool
+		current    *pathItem
+	)
+	each := func(value any) (string, error) {
+		if current != nil {
+			return current.name, nil
+		}
+		if called {
+			return "", errors.New("each can only be called once")
+		}
+		called, collection = true, value
+		return "", nil
+	}
+
+	tmpl, err := template.New(rel).
+		Funcs(ro.funcs).
+		Funcs(template.FuncMap{"each": each}).
+		Option("missingkey=error").
+		Parse(rel)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+	execute := func(data any) (string, error) {
+		var builder strings.Builder
+		if err := tmpl.Execute(&builder, data); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidPath, err)
+		}
+		return builder.String(), nil
+	}
+
+	// first execution to find out whether the path must be expanded into multiple ones
+	result, err := execute(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if !called {
+		if !validElements(result) {
+			return nil, nil
+		}
+		return []expandedPath{{rel: result}}, checkPath(result)
+	}
+
+	items, err := pathItems(collection)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+	paths := make([]expandedPath, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		data := PathData{Metadata: metadata, Name: item.name, Item: item.value}
+		current = &item
+		result, err := execute(data)
+		if err != nil {
+			return nil, err
+		}
+		if !validElements(result) {
+			continue
+		}
+		if err := checkPath(result); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[result]; ok {
+			return nil, fmt.Errorf("%w: '%s' is produced more than once", ErrInvalidPath, result)
+		}
+		seen[result] = struct{}{}
+		paths = append(paths, expandedPath{data: data, rel: result})
+	}
+	return paths, nil
+}
+
+// validElements returns truthy in case none of rel elements is empty.
+func validElements(rel string) bool {
+	return !slices.Contains(strings.Split(rel, "/"), "")
+}
+
+// checkPath returns an error in case rel isn't a valid relative path inside destination directory (e.g. with ".." elements).
+func checkPath(rel string) error {
+	if !fs.ValidPath(rel) || rel == "." {
+		return fmt.Errorf("%w: '%s' isn't a valid relative path", ErrInvalidPath, rel)
+	}
+	return nil
+}
+
+// pathItems returns the items of the input map or slice (or array), maps items being sorted by name.
+func pathItems(collection any) ([]pathItem, error) {
+	value := reflect.ValueOf(collection)
+	switch value.Kind() {
+	case reflect.Map:
+		items := make([]pathItem, 0, value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			items = append(items, pathItem{name: fmt.Sprint(iter.Key().Interface()), value: iter.Value().Interface()})
+		}
+		slices.SortFunc(items, func(a, b pathItem) int { return cmp.Compare(a.name, b.name) })
+		return items, nil
+	case reflect.Slice, reflect.Array:
+		items := make([]pathItem, 0, value.Len())
+		for i := range value.Len() {
+			element := value.Index(i).Interface()
+			items = append(items, pathItem{name: fmt.Sprint(element), value: element})
+		}
+		return items, nil
+	case reflect.Invalid:
+		return nil, nil // nil collection
+	default:
+		return nil, fmt.Errorf("each must be given a map or a slice, got %s", value.Kind())
+	}
+}
@@ -0,0 +1,35 @@
+/*
+Package plugin provides generate.Parser and generate.Handler implementations backed by external executables (plugins),
+allowing to extend craft generation in any language without compiling Go code against craft SDK.
+
+Each plugin call runs the plugin executable with a Request written as JSON on its standard input.
+The plugin must write its response as JSON on its standard output (a Description, Mutations or Decision depending on Request kind)
+and may write logs on its standard error (they're routed through craft logger).
+
+Example of a shell plugin detecting python projects and generating a setup.cfg:
+
+	#!/bin/sh
+	read -r input
+	case "$input" in
+	*'"kind":"describe"'*) echo '{"parser":true,"handler":true,"names":["setup.cfg"]}' ;;
+	*'"kind":"parser"'*) [ -f pyproject.toml ] && echo '{"languages":{"python":{}}}' || echo '{}' ;;
+	*'"kind":"handler"'*) echo '{"handled":true,"language":"python"}' ;;
+	esac
+
+Example with generate.Run:
+
+	func main() {
+		ctx := context.Background()
+
+		plugins, err := plugin.Discover(ctx, clog.Noop(), "path/to/plugin")
+		if err != nil {
+			// handle err
+		}
+
+		config, report, err := generate.Run(ctx, config,
+			generate.WithHandlers(handler.Defaults(plugin.Handlers(ctx, plugins...)...)...),
+			generate.WithParserNodes(parser.Defaults(plugin.Parsers(plugins...)...)...))
+		// handle err
+	}
+*/
+package plugin
@@ -0,0 +1,332 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/clog"
+
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+// Prefix is the executables names prefix of plugins discovered in PATH.
+const Prefix = "craft-plugin-"
+
+// HandlerTimeout is the maximum duration of a KindHandler request,
+// after which the plugin is killed and the generation fails.
+const HandlerTimeout = 30 * time.Second
+
+// Plugin is an external executable extending craft generation with a parser and/or an handler.
+//
+// It's run once per request with a Request written as JSON on its standard input
+// and must write its response as JSON on its standard output.
+// Its standard error is routed through craft logger.
+type Plugin struct {
+	Description
+
+	// Path is the plugin executable path.
+	Path string
+
+	log clog.Logger
+}
+
+// Discover returns all plugins found in PATH (executables prefixed by Prefix)
+// and the input ones (either names found in PATH or paths to executables), described with a KindDescribe request.
+//
+// In case a plugin is found multiple times in PATH, only the first one is kept, as with exec.LookPath.
+func Discover(ctx context.Context, log clog.Logger, names ...string) ([]Plugin, error) {
+	if log == nil {
+		log = clog.Noop()
+	}
+
+	found := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, _ := os.ReadDir(dir) // PATH may contain unreadable or non existing directories
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), ".exe")
+			if _, ok := found[name]; ok || !strings.HasPrefix(name, Prefix) || !isExecutable(dir, entry.Name()) {
+				continue
+			}
+			found[name] = filepath.Join(dir, entry.Name())
+		}
+	}
+	paths := make([]string, 0, len(found)+len(names))
+	for _, name := range slices.Sorted(maps.Keys(found)) {
+		paths = append(paths, found[name])
+	}
+
+	errs := make([]error, 0, len(names))
+	for _, name := range names {
+		executable, err := exec.LookPath(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin '%s': %w", name, err))
+			continue
+		}
+		if !slices.Contains(paths, executable) {
+			paths = append(paths, executable)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	plugins := make([]Plugin, 0, len(paths))
+	for _, executable := range paths {
+		plugin := Plugin{Path: executable, log: log}
+		if err := plugin.call(ctx, Request{Kind: KindDescribe}, &plugin.Description); err != nil {
+			return nil, err
+		}
+		log.Debugf("plugin '%s' discovered (parser: %t, handler: %t)", executable, plugin.Parser, plugin.Handler)
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// isExecutable returns truthy in case name in dir is an executable regular file.
+func isExecutable(dir, name string) bool {
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.HasSuffix(name, ".exe")
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// Parsers returns the generate.ParserNode of all input plugins implementing a parser.
+func Parsers(plugins ...Plugin) []generate.ParserNode {
+	parsers := make([]generate.ParserNode, 0, len(plugins))
+	for _, plugin := range plugins {
+		if plugin.Parser {
+			parsers = append(parsers, generate.ParserNode{
+				Name:     filepath.Base(plugin.Path),
+				Parser:   plugin.Parse,
+				Consumes: plugin.Consumes,
+				Provides: plugin.Provides,
+			})
+		}
+	}
+	return parsers
+}
+
+// Handlers returns the generate.Handler of all input plugins implementing an handler.
+//
+// Plugins handler requests are stopped when ctx is done (e.g. on generation interruption).
+func Handlers(ctx context.Context, plugins ...Plugin) []generate.Handler {
+	handlers := make([]generate.Handler, 0, len(plugins))
+	for _, plugin := range plugins {
+		if plugin.Handler {
+			handlers = append(handlers, func(src, dest, name string) (generate.HandlerResult, bool) {
+				return plugin.handle(ctx, src, dest, name)
+			})
+		}
+	}
+	return handlers
+}
+
+// Parse sends a KindParser request to the plugin and applies its Mutations on metadata.
+func (p Plugin) Parse(ctx context.Context, destdir string, metadata *generate.Metadata) error {
+	req := Request{
+		Kind:      KindParser,
+		Destdir:   destdir,
+		Languages: slices.Sorted(maps.Keys(metadata.Languages)),
+		Metadata:  newMetadata(*metadata),
+	}
+	var mutations Mutations
+	if err := p.call(ctx, req, &mutations); err != nil {
+		return err
+	}
+
+	maps.Copy(metadata.Languages, mutations.Languages)
+	if mutations.ProjectHost != "" {
+		metadata.ProjectHost = mutations.ProjectHost
+	}
+	if mutations.ProjectName != "" {
+		metadata.ProjectName = mutations.ProjectName
+	}
+	if mutations.ProjectPath != "" {
+		metadata.ProjectPath = mutations.ProjectPath
+	}
+
+	// binaries are counted as golang parser does (one per new executable)
+	// since some parsers (e.g. node) count binaries without any name
+	added := addNames(metadata.Clis, mutations.Clis) +
+		addNames(metadata.Crons, mutations.Crons) +
+		addNames(metadata.Jobs, mutations.Jobs) +
+		addNames(metadata.Workers, mutations.Workers)
+	metadata.Binaries += uint8(added) //nolint:gosec
+	return nil
+}
+
+var _ generate.Parser = Plugin{}.Parse // ensure interface is implemented
+
+// newMetadata returns the Metadata given to plugins from generation metadata.
+func newMetadata(metadata generate.Metadata) *Metadata {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	names := func(m map[string]struct{}) []string {
+		if len(m) == 0 {
+			return nil
+		}
+		return slices.Sorted(maps.Keys(m))
+	}
+
+	result := &Metadata{
+		Bot:          value(metadata.Bot),
+		Description:  value(metadata.Description),
+		Docker:       metadata.Docker,
+		License:      value(metadata.License),
+		Maintainers:  metadata.Maintainers,
+		NoChart:      metadata.NoChart,
+		NoGoreleaser: metadata.NoGoreleaser,
+		NoMakefile:   metadata.NoMakefile,
+		NoReadme:     metadata.NoReadme,
+		Platform:     metadata.Platform,
+		Languages:    metadata.Languages,
+		ProjectHost:  metadata.ProjectHost,
+		ProjectName:  metadata.ProjectName,
+		ProjectPath:  metadata.ProjectPath,
+		Binaries:     metadata.Binaries,
+		Clis:         names(metadata.Clis),
+		Crons:        names(metadata.Crons),
+		Jobs:         names(metadata.Jobs),
+		Workers:      names(metadata.Workers),
+	}
+	if ci := metadata.CI; ci != nil {
+		result.CI = &CI{
+			Auth:    Auth{Maintenance: value(ci.Auth.Maintenance), Release: value(ci.Auth.Release)},
+			Name:    ci.Name,
+			Options: ci.Options,
+		}
+		if ci.Release != nil {
+			result.CI.Release = &Release{Auto: ci.Release.Auto, Backmerge: ci.Release.Backmerge}
+		}
+		if ci.Static != nil {
+			result.CI.Static = &Static{Auto: ci.Static.Auto, Name: ci.Static.Name}
+		}
+	}
+	return result
+}
+
+// addNames adds all names into dest and returns the number of names not already present.
+func addNames(dest map[string]struct{}, names []string) int {
+	var added int
+	for _, name := range names {
+		if _, ok := dest[name]; !ok {
+			dest[name] = struct{}{}
+			added++
+		}
+	}
+	return added
+}
+
+// Handle sends a KindHandler request to the plugin (only when name matches one of its Names)
+// and returns the generate.HandlerResult corresponding to its Decision.
+//
+// In case the plugin fails (or doesn't answer within HandlerTimeout), the file is handled with a generate.FailedResult
+// making the generation fail, since considering it as not handled would remove the files previously generated by the plugin.
+//
+// Handlers should prefer Handlers to stop requests when generation is interrupted.
+func (p Plugin) Handle(src, dest, name string) (generate.HandlerResult, bool) {
+	return p.handle(context.Background(), src, dest, name)
+}
+
+var _ generate.Handler = Plugin{}.Handle // ensure interface is implemented
+
+// handle is Handle with ctx stopping the plugin request.
+func (p Plugin) handle(ctx context.Context, src, dest, name string) (generate.HandlerResult, bool) {
+	if len(p.Names) > 0 && !slices.ContainsFunc(p.Names, func(glob string) bool {
+		ok, _ := path.Match(glob, name)
+		return ok
+	}) {
+		return generate.HandlerResult{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, HandlerTimeout)
+	defer cancel()
+
+	var decision Decision
+	if err := p.call(ctx, Request{Kind: KindHandler, Dest: dest, Name: name, Src: src}, &decision); err != nil {
+		return generate.FailedResult(err), true
+	}
+	if !decision.Handled {
+		return generate.HandlerResult{}, false
+	}
+
+	result := generate.HandlerResult{
+		Delimiter: generate.DelimiterBracket(),
+		Globs:     decision.Globs,
+		Disabled: func(metadata generate.Metadata) bool {
+			_, ok := metadata.Languages[decision.Language]
+			return decision.Language != "" && !ok
+		},
+		ShouldGenerate: func(generate.Metadata) bool {
+			if decision.Generate != nil {
+				return *decision.Generate
+			}
+			return generate.IsGenerated(dest)
+		},
+		ShouldRemove: func(generate.Metadata) bool { return decision.Remove != nil && *decision.Remove },
+	}
+	if decision.StartDelim != "" && decision.EndDelim != "" {
+		result.Delimiter = generate.Delimiter{EndDelim: decision.EndDelim, StartDelim: decision.StartDelim}
+	}
+	if len(result.Globs) == 0 {
+		result.Globs = []string{src}
+	}
+	return result, true
+}
+
+// call runs the plugin with req as JSON on its standard input and decodes its standard output into resp.
+func (p Plugin) call(ctx context.Context, req Request, resp any) error {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("plugin '%s': encode %s request: %w", p.Path, req.Kind, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Dir = req.Destdir // parsers are run in project directory
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &stdout, &stderr
+	cmd.WaitDelay = time.Second // don't wait for plugin children still holding outputs once it's killed
+	err = cmd.Run()
+
+	for _, line := range strings.Split(strings.TrimRight(stderr.String(), "\n"), "\n") {
+		if line != "" {
+			p.logger(ctx).Infof("%s: %s", filepath.Base(p.Path), strings.TrimSuffix(line, "\r"))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("plugin '%s': run %s request: %w", p.Path, req.Kind, err)
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return fmt.Errorf("plugin '%s': decode %s response: %w", p.Path, req.Kind, err)
+	}
+	return nil
+}
+
+// logger returns the plugin logger given to Discover or ctx one otherwise.
+func (p Plugin) logger(ctx context.Context) clog.Logger {
+	if p.log == nil {
+		return generate.GetLogger(ctx)
+	}
+	return p.log
+}
@@ -0,0 +1,288 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+	"github.com/kilianpaquier/craft/pkg/generate/plugin"
+)
+
+// python is a plugin detecting python projects (with a pyproject.toml) and handling setup.cfg files.
+const python = `#!/bin/sh
+read -r input
+case "$input" in
+*'"kind":"describe"'*) echo '{"parser":true,"handler":true,"names":["setup.cfg"]}' ;;
+*'"kind":"parser"'*)
+  echo "parsing $(pwd)" >&2
+  [ -f pyproject.toml ] && echo '{"languages":{"python":{}},"projectName":"plugged","workers":["worker"]}' || echo '{}' ;;
+*'"kind":"handler"'*) echo '{"handled":true,"language":"python","startDelim":"<<","endDelim":">>"}' ;;
+esac
+`
+
+// newPlugin writes an executable plugin named name with content in a new temporary directory and returns its path.
+func newPlugin(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), cfs.RwxRxRxRx))
+	return path
+}
+
+func TestDiscover(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success_path", func(t *testing.T) {
+		// Arrange
+		executable := newPlugin(t, "craft-plugin-python", python)
+		dir := filepath.Dir(executable)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "craft-plugin-not-executable"), []byte(python), cfs.RwRR))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), []byte(python), cfs.RwxRxRxRx))
+		t.Setenv("PATH", dir)
+
+		// Act
+		plugins, err := plugin.Discover(ctx, nil)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, plugins, 1)
+		assert.Equal(t, executable, plugins[0].Path)
+		assert.Equal(t, plugin.Description{Handler: true, Names: []string{"setup.cfg"}, Parser: true}, plugins[0].Description)
+	})
+
+	t.Run("success_names", func(t *testing.T) {
+		// Arrange
+		executable := newPlugin(t, "python", python)
+		t.Setenv("PATH", "")
+
+		// Act
+		plugins, err := plugin.Discover(ctx, nil, executable)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, plugins, 1)
+		assert.Equal(t, executable, plugins[0].Path)
+	})
+
+	t.Run("error_not_found", func(t *testing.T) {
+		// Arrange
+		t.Setenv("PATH", "")
+
+		// Act
+		_, err := plugin.Discover(ctx, nil, "craft-plugin-unknown")
+
+		// Assert
+		assert.ErrorContains(t, err, "plugin 'craft-plugin-unknown'")
+	})
+
+	t.Run("error_invalid_description", func(t *testing.T) {
+		// Arrange
+		executable := newPlugin(t, "invalid", "#!/bin/sh\necho invalid\n")
+
+		// Act
+		_, err := plugin.Discover(ctx, nil, executable)
+
+		// Assert
+		assert.ErrorContains(t, err, "decode describe response")
+	})
+}
+
+func TestPlugin_Parse(t *testing.T) {
+	ctx := context.Background()
+	executable := newPlugin(t, "python", python)
+
+	t.Run("success_not_detected", func(t *testing.T) {
+		// Arrange
+		metadata := generate.Metadata{Languages: map[string]any{}}
+
+		// Act
+		err := plugin.Plugin{Path: executable}.Parse(ctx, t.TempDir(), &metadata)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, metadata.Languages)
+	})
+
+	t.Run("success_detected", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "pyproject.toml"), nil, cfs.RwRR))
+		metadata := generate.Metadata{Languages: map[string]any{"golang": nil}, Workers: map[string]struct{}{}}
+
+		// Act
+		err := plugin.Plugin{Path: executable}.Parse(ctx, destdir, &metadata)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"golang": nil, "python": map[string]any{}}, metadata.Languages)
+		assert.Equal(t, "plugged", metadata.ProjectName)
+		assert.Equal(t, map[string]struct{}{"worker": {}}, metadata.Workers)
+		assert.Equal(t, uint8(1), metadata.Binaries)
+	})
+
+	t.Run("success_binaries_not_counted_twice", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "pyproject.toml"), nil, cfs.RwRR))
+		metadata := generate.Metadata{Binaries: 2, Languages: map[string]any{}, Workers: map[string]struct{}{"worker": {}}}
+
+		// Act
+		err := plugin.Plugin{Path: executable}.Parse(ctx, destdir, &metadata)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, uint8(2), metadata.Binaries)
+	})
+
+	t.Run("success_metadata_sent", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		executable := newPlugin(t, "recorder", "#!/bin/sh\ncat > request.json\necho '{}'\n")
+		license := "mit"
+		metadata := generate.Metadata{
+			Configuration: craft.Configuration{
+				CI:      &craft.CI{Name: "github", Options: []string{"codecov"}, Release: &craft.Release{Auto: true}},
+				License: &license,
+			},
+			Binaries:  1,
+			Clis:      map[string]struct{}{"second": {}, "first": {}},
+			Languages: map[string]any{"golang": map[string]any{"module": "github.com/kilianpaquier/craft"}},
+		}
+
+		// Act
+		err := plugin.Plugin{Path: executable}.Parse(ctx, destdir, &metadata)
+
+		// Assert
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(destdir, "request.json"))
+		require.NoError(t, err)
+		var req plugin.Request
+		require.NoError(t, json.Unmarshal(content, &req))
+		assert.Equal(t, &plugin.Metadata{
+			CI:        &plugin.CI{Name: "github", Options: []string{"codecov"}, Release: &plugin.Release{Auto: true}},
+			License:   "mit",
+			Binaries:  1,
+			Clis:      []string{"first", "second"},
+			Languages: map[string]any{"golang": map[string]any{"module": "github.com/kilianpaquier/craft"}},
+		}, req.Metadata)
+	})
+
+	t.Run("error_run", func(t *testing.T) {
+		// Arrange
+		executable := newPlugin(t, "failing", "#!/bin/sh\nexit 1\n")
+
+		// Act
+		err := plugin.Plugin{Path: executable}.Parse(ctx, t.TempDir(), &generate.Metadata{})
+
+		// Assert
+		assert.ErrorContains(t, err, "run parser request: exit status 1")
+	})
+}
+
+func TestPlugin_Handle(t *testing.T) {
+	executable := newPlugin(t, "python", python)
+	p := plugin.Plugin{Description: plugin.Description{Handler: true, Names: []string{"setup.cfg"}}, Path: executable}
+
+	t.Run("success_not_matching_names", func(t *testing.T) {
+		// Act
+		_, ok := p.Handle("_templates/setup.py.tmpl", "setup.py", "setup.py")
+
+		// Assert
+		assert.False(t, ok)
+	})
+
+	t.Run("success_handled", func(t *testing.T) {
+		// Act
+		result, ok := p.Handle("_templates/setup.cfg.tmpl", filepath.Join(t.TempDir(), "setup.cfg"), "setup.cfg")
+
+		// Assert
+		require.True(t, ok)
+		assert.Equal(t, generate.DelimiterChevron(), result.Delimiter)
+		assert.Equal(t, []string{"_templates/setup.cfg.tmpl"}, result.Globs)
+		assert.True(t, result.Disabled(generate.Metadata{}))
+		assert.False(t, result.Disabled(generate.Metadata{Languages: map[string]any{"python": nil}}))
+		assert.True(t, result.ShouldGenerate(generate.Metadata{Languages: map[string]any{"python": nil}}))
+		assert.False(t, result.ShouldRemove(generate.Metadata{}))
+	})
+
+	render := func(handler generate.Handler) error {
+		templates := fstest.MapFS{"_templates/setup.cfg.tmpl": {Data: []byte("[metadata]\n")}}
+		_, err := generate.Render(context.Background(), generate.Metadata{}, "setup.cfg",
+			generate.WithHandlers(handler),
+			generate.WithTemplates("_templates", templates))
+		return err
+	}
+
+	t.Run("error_canceled", func(t *testing.T) {
+		// Arrange
+		executable := newPlugin(t, "hanging", "#!/bin/sh\nsleep 10\n")
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		t.Cleanup(cancel)
+		handlers := plugin.Handlers(ctx, plugin.Plugin{Description: plugin.Description{Handler: true}, Path: executable})
+		require.Len(t, handlers, 1)
+		start := time.Now()
+
+		// Act
+		err := render(handlers[0])
+
+		// Assert
+		assert.ErrorContains(t, err, "plugin '"+executable+"'")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("error_failing", func(t *testing.T) {
+		// Arrange
+		executable := newPlugin(t, "failing", "#!/bin/sh\nexit 1\n")
+
+		// Act
+		err := render(plugin.Plugin{Path: executable}.Handle)
+
+		// Assert
+		assert.ErrorContains(t, err, "plugin '"+executable+"'")
+	})
+
+	t.Run("error_invalid_decision", func(t *testing.T) {
+		// Arrange
+		executable := newPlugin(t, "invalid", "#!/bin/sh\necho '{invalid'\n")
+
+		// Act
+		err := render(plugin.Plugin{Path: executable}.Handle)
+
+		// Assert
+		assert.ErrorContains(t, err, "plugin '"+executable+"'")
+	})
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	// Arrange
+	destdir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(destdir, "pyproject.toml"), nil, cfs.RwRR))
+	templates := fstest.MapFS{"_templates/setup.cfg.tmpl": {Data: []byte("[metadata]\nname = <<.ProjectName>>\n")}}
+
+	plugins, err := plugin.Discover(ctx, nil, newPlugin(t, "python", python))
+	require.NoError(t, err)
+
+	// Act
+	_, _, err = generate.Run(ctx, craft.Configuration{},
+		generate.WithDestination(destdir),
+		generate.WithHandlers(plugin.Handlers(ctx, plugins...)...),
+		generate.WithParserNodes(plugin.Parsers(plugins...)...),
+		generate.WithTemplates("_templates", templates))
+
+	// Assert
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(destdir, "setup.cfg"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Code generated by craft from setup.cfg.tmpl; DO NOT EDIT.\n\n[metadata]\nname = plugged\n", string(content))
+}
@@ -0,0 +1,155 @@
+package plugin
+
+import "github.com/kilianpaquier/craft/pkg/craft"
+
+const (
+	// KindDescribe is the Request kind asking a plugin what it implements (answered with a Description).
+	KindDescribe = "describe"
+
+	// KindHandler is the Request kind asking a plugin whether it handles a template file (answered with a Decision).
+	KindHandler = "handler"
+
+	// KindParser is the Request kind asking a plugin to parse the project (answered with Mutations).
+	KindParser = "parser"
+)
+
+// Request is the JSON object written on plugins standard input.
+//
+// Destdir, Languages and Metadata are only given with KindParser
+// and Src, Dest and Name with KindHandler.
+type Request struct {
+	Kind string `json:"kind"`
+
+	Destdir   string    `json:"destdir,omitempty"`
+	Languages []string  `json:"languages,omitempty"`
+	Metadata  *Metadata `json:"metadata,omitempty"`
+
+	Dest string `json:"dest,omitempty"`
+	Name string `json:"name,omitempty"`
+	Src  string `json:"src,omitempty"`
+}
+
+// Metadata is the generation metadata (see generate.Metadata) given to plugins parsers.
+//
+// It's defined explicitly since generate.Metadata (and craft.Configuration) JSON tags are the ones of templating data
+// where most properties are omitted.
+type Metadata struct {
+	Bot          string              `json:"bot,omitempty"`
+	CI           *CI                 `json:"ci,omitempty"`
+	Description  string              `json:"description,omitempty"`
+	Docker       *craft.Docker       `json:"docker,omitempty"`
+	License      string              `json:"license,omitempty"`
+	Maintainers  []*craft.Maintainer `json:"maintainers,omitempty"`
+	NoChart      bool                `json:"noChart,omitempty"`
+	NoGoreleaser bool                `json:"noGoreleaser,omitempty"`
+	NoMakefile   bool                `json:"noMakefile,omitempty"`
+	NoReadme     bool                `json:"noReadme,omitempty"`
+	Platform     string              `json:"platform,omitempty"`
+
+	// Languages is the map of detected languages with their specificities.
+	Languages map[string]any `json:"languages,omitempty"`
+
+	ProjectHost string `json:"projectHost,omitempty"`
+	ProjectName string `json:"projectName,omitempty"`
+	ProjectPath string `json:"projectPath,omitempty"`
+
+	// Binaries is the total number of binaries (see generate.Metadata).
+	Binaries uint8 `json:"binaries,omitempty"`
+
+	// Clis, Crons, Jobs and Workers are sorted names.
+	Clis    []string `json:"clis,omitempty"`
+	Crons   []string `json:"crons,omitempty"`
+	Jobs    []string `json:"jobs,omitempty"`
+	Workers []string `json:"workers,omitempty"`
+}
+
+// CI is the continuous integration configuration (see craft.CI) in Metadata.
+type CI struct {
+	Auth    Auth     `json:"auth"`
+	Name    string   `json:"name"`
+	Options []string `json:"options,omitempty"`
+	Release *Release `json:"release,omitempty"`
+	Static  *Static  `json:"static,omitempty"`
+}
+
+// Auth is the continuous integration authentication methods (see craft.Auth) in Metadata.
+type Auth struct {
+	Maintenance string `json:"maintenance,omitempty"`
+	Release     string `json:"release,omitempty"`
+}
+
+// Release is the continuous integration release configuration (see craft.Release) in Metadata.
+type Release struct {
+	Auto      bool `json:"auto,omitempty"`
+	Backmerge bool `json:"backmerge,omitempty"`
+}
+
+// Static is the static deployment configuration (see craft.Static) in Metadata.
+type Static struct {
+	Auto bool   `json:"auto,omitempty"`
+	Name string `json:"name"`
+}
+
+// Description is the JSON object written by plugins on standard output for KindDescribe requests.
+type Description struct {
+	// Consumes and Provides are the metadata fields read and set by the parser (see generate.ParserNode).
+	//
+	// When both are empty, the parser runs after all craft parsers (except Helm) and other plugins found before it.
+	Consumes []string `json:"consumes,omitempty"`
+	Provides []string `json:"provides,omitempty"`
+
+	// Handler is whether the plugin handles some template files (with KindHandler requests).
+	Handler bool `json:"handler,omitempty"`
+
+	// Names is the slice of globs (see path.Match) of files names the plugin is asked about with KindHandler requests.
+	//
+	// When empty, the plugin is asked about all files not handled by other handlers.
+	Names []string `json:"names,omitempty"`
+
+	// Parser is whether the plugin parses the project (with KindParser requests).
+	Parser bool `json:"parser,omitempty"`
+}
+
+// Mutations is the JSON object written by plugins on standard output for KindParser requests.
+//
+// All non empty properties are applied on generation metadata.
+type Mutations struct {
+	// Languages is the map of detected languages with their specificities,
+	// they're added to metadata languages (overriding existing ones with the same name).
+	Languages map[string]any `json:"languages,omitempty"`
+
+	ProjectHost string `json:"projectHost,omitempty"`
+	ProjectName string `json:"projectName,omitempty"`
+	ProjectPath string `json:"projectPath,omitempty"`
+
+	// Clis, Crons, Jobs and Workers are added to metadata ones, metadata Binaries being incremented for each new name.
+	Clis    []string `json:"clis,omitempty"`
+	Crons   []string `json:"crons,omitempty"`
+	Jobs    []string `json:"jobs,omitempty"`
+	Workers []string `json:"workers,omitempty"`
+}
+
+// Decision is the JSON object written by plugins on standard output for KindHandler requests.
+type Decision struct {
+	// Handled is whether the plugin handles the template file, all other properties are ignored when it's false.
+	Handled bool `json:"handled"`
+
+	// EndDelim and StartDelim are the go template delimiters of the template file ({{ and }} by default).
+	EndDelim   string `json:"endDelim,omitempty"`
+	StartDelim string `json:"startDelim,omitempty"`
+
+	// Globs is the slice of templates to parse (see generate.HandlerResult), only the template file by default.
+	//
+	// They must be in the same templates filesystem as Request src (e.g. "_templates/setup-*.part.tmpl").
+	Globs []string `json:"globs,omitempty"`
+
+	// Generate is whether the file must be generated.
+	// By default, it's generated when it doesn't exist or is a generated file.
+	Generate *bool `json:"generate,omitempty"`
+
+	// Language restricts the file generation to projects where this language was detected by parsers.
+	Language string `json:"language,omitempty"`
+
+	// Remove is whether the file must be removed (false by default).
+	Remove *bool `json:"remove,omitempty"`
+}
@@ -0,0 +1,116 @@
+package generate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// regionMarker matches managed regions begin and end markers lines.
+//
+// All comment syntaxes of files generated by craft are supported:
+//
+//	# craft:begin golang      (YAML, Makefile, shell, .gitignore, .dockerignore, etc.)
+//	// craft:begin golang     (JSON5, etc.)
+//	<!-- craft:begin golang --> (Markdown, HTML, etc.)
+//
+// The end marker is the same without the region name (e.g. "# craft:end").
+var regionMarker = regexp.MustCompile(`^\s*(?:#|//|<!--)\s*craft:(begin|end)(?:\s+([\w.-]+))?\s*(?:-->)?\s*$`)
+
+// ErrInvalidRegion is the error returned (wrapped) when managed regions markers of a file are invalid
+// (nested regions, end marker without begin marker, etc.).
+var ErrInvalidRegion = errors.New("invalid managed region")
+
+// region is a managed region of a file with the lines indexes of its markers.
+type region struct {
+	name  string
+	begin int
+	end   int
+}
+
+// hasRegions returns truthy in case content has at least one managed region marker.
+func hasRegions(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		if regionMarker.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRegions returns all managed regions of input lines.
+func parseRegions(lines []string) ([]region, error) {
+	var regions []region
+	current := -1 // index of the currently opened region
+	for index, line := range lines {
+		matches := regionMarker.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		switch {
+		case matches[1] == "begin" && current >= 0:
+			return nil, fmt.Errorf("%w: line %d: region '%s' opened inside region '%s'", ErrInvalidRegion, index+1, matches[2], regions[current].name)
+		case matches[1] == "begin":
+			regions = append(regions, region{name: matches[2], begin: index})
+			current = len(regions) - 1
+		case current < 0:
+			return nil, fmt.Errorf("%w: line %d: end marker without begin marker", ErrInvalidRegion, index+1)
+		default:
+			regions[current].end = index
+			current = -1
+		}
+	}
+	if current >= 0 {
+		return nil, fmt.Errorf("%w: region '%s' is never closed", ErrInvalidRegion, regions[current].name)
+	}
+	return regions, nil
+}
+
+// mergeRegions replaces the content of all managed regions of content with render result.
+//
+// render is called with each region name, an empty name standing for the whole file.
+// Lines outside regions and markers are kept as is.
+func mergeRegions(content []byte, render func(name string) ([]byte, error)) ([]byte, error) {
+	lines := strings.SplitAfter(string(content), "\n")
+	regions, err := parseRegions(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged bytes.Buffer
+	previous := 0
+	for _, region := range regions {
+		for _, line := range lines[previous : region.begin+1] {
+			merged.WriteString(line)
+		}
+
+		rendered, err := render(region.name)
+		if err != nil {
+			return nil, fmt.Errorf("region '%s': %w", region.name, err)
+		}
+		if body := regionBody(rendered); body != "" {
+			merged.WriteString(body)
+			merged.WriteString("\n")
+		}
+		previous = region.end
+	}
+	for _, line := range lines[previous:] {
+		merged.WriteString(line)
+	}
+	return merged.Bytes(), nil
+}
+
+// regionBody returns rendered without generated header and surrounding blank lines.
+func regionBody(rendered []byte) string {
+	lines := strings.Split(string(rendered), "\n")
+	body := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if !generatedRegexp.MatchString(line) {
+			body = append(body, line)
+		}
+	}
+	return strings.Trim(strings.Join(body, "\n"), "\n")
+}
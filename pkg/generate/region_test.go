@@ -0,0 +1,101 @@
+package generate //nolint:testpackage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeRegions(t *testing.T) {
+	render := func(name string) ([]byte, error) {
+		switch name {
+		case "":
+			return []byte("# Code generated by craft; DO NOT EDIT.\n\nwhole\n"), nil
+		case "unknown":
+			return nil, errors.New("no such template")
+		default:
+			return []byte("\n" + name + " content\n"), nil
+		}
+	}
+
+	t.Run("success_comment_syntaxes", func(t *testing.T) {
+		// Arrange
+		content := "user\n" +
+			"# craft:begin golang\nold\n# craft:end\n" +
+			"  // craft:begin node\n  // craft:end\n" +
+			"<!-- craft:begin hugo -->\nold\nold\n<!-- craft:end -->\n" +
+			"user\n"
+
+		// Act
+		merged, err := mergeRegions([]byte(content), render)
+
+		// Assert
+		require.NoError(t, err)
+		expected := "user\n" +
+			"# craft:begin golang\ngolang content\n# craft:end\n" +
+			"  // craft:begin node\nnode content\n  // craft:end\n" +
+			"<!-- craft:begin hugo -->\nhugo content\n<!-- craft:end -->\n" +
+			"user\n"
+		assert.Equal(t, expected, string(merged))
+	})
+
+	t.Run("success_whole_file", func(t *testing.T) {
+		// Act
+		merged, err := mergeRegions([]byte("user\n# craft:begin\n# craft:end"), render)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "user\n# craft:begin\nwhole\n# craft:end", string(merged))
+	})
+
+	t.Run("success_versioned_header", func(t *testing.T) {
+		// Arrange
+		render := func(string) ([]byte, error) {
+			return withHeader([]byte("whole\n"), header("Makefile", "v1.0.0", "Makefile.tmpl")), nil
+		}
+
+		// Act
+		merged, err := mergeRegions([]byte("user\n# craft:begin\n# craft:end"), render)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "user\n# craft:begin\nwhole\n# craft:end", string(merged))
+	})
+
+	t.Run("error_render", func(t *testing.T) {
+		// Act
+		_, err := mergeRegions([]byte("# craft:begin unknown\n# craft:end\n"), render)
+
+		// Assert
+		assert.ErrorContains(t, err, "region 'unknown': no such template")
+	})
+
+	t.Run("error_nested", func(t *testing.T) {
+		// Act
+		_, err := mergeRegions([]byte("# craft:begin golang\n# craft:begin node\n# craft:end\n"), render)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidRegion)
+		assert.ErrorContains(t, err, "line 2: region 'node' opened inside region 'golang'")
+	})
+
+	t.Run("error_end_without_begin", func(t *testing.T) {
+		// Act
+		_, err := mergeRegions([]byte("user\n# craft:end\n"), render)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidRegion)
+		assert.ErrorContains(t, err, "line 2: end marker without begin marker")
+	})
+
+	t.Run("error_not_closed", func(t *testing.T) {
+		// Act
+		_, err := mergeRegions([]byte("# craft:begin golang\n"), render)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidRegion)
+		assert.ErrorContains(t, err, "region 'golang' is never closed")
+	})
+}
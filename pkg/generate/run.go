@@ -1,29 +1,226 @@
 package generate
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+	"maps"
 	"path"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"text/template"
+	"time"
 
-	"github.com/Masterminds/sprig/v3"
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
 
 	"github.com/kilianpaquier/craft/pkg/craft"
-	"github.com/kilianpaquier/craft/pkg/templating"
 )
 
 // Run is the main function from generate package.
 // It takes a craft configuration and various run options.
 //
-// It executes all parsers given in options (or default ones)
+// It executes all parsers given in options, ordered by their dependencies (see ParserNode),
 // and then dives into all directories from option filesystem (or default one)
 // to generates template files (.tmpl) specified by the handlers returned from parsers.
-func Run(parent context.Context, config craft.Configuration, opts ...RunOption) (craft.Configuration, error) {
-	meta := Metadata{
+//
+// It returns the Report of all changes done on destination directory (or to be done when WithDryRun is given).
+//
+// Changes are staged and only applied once all parsers and handlers succeeded (see WithPartialOutput to change this behavior).
+// In case applying one of them fails, the already applied ones are rolled back.
+func Run(parent context.Context, config craft.Configuration, opts ...RunOption) (craft.Configuration, Report, error) {
+	start := time.Now()
+	meta := newMetadata(config)
+
+	ro, err := newRunOpt(opts...)
+	if err != nil {
+		return meta.Configuration, Report{}, fmt.Errorf("parse run options: %w", err)
+	}
+	if ro.lock {
+		if ro.previous, err = readLock(ro.output); err != nil {
+			return meta.Configuration, Report{}, fmt.Errorf("read lock: %w", err)
+		}
+	}
+	rec := &recorder{destdir: *ro.destdir, dryRun: ro.dryRun, excluded: config.IsExcluded, out: ro.output, stage: !ro.partial}
+	ctx := context.WithValue(parent, loggerKey, ro.logger)
+	ctx = context.WithValue(ctx, recorderKey, rec)
+
+	var provenance map[string]string
+	report := func() Report {
+		return Report{Changes: rec.changes, Duration: time.Since(start), Provenance: provenance}
+	}
+
+	hooks := ro.hooks(ctx, config)
+	if err := runHooks(ctx, *ro.destdir, "pre", hooks.Pre); err != nil {
+		return meta.Configuration, report(), err
+	}
+
+	provenance, err = runParsers(ctx, *ro.destdir, ro.levels, &meta)
+	if err != nil {
+		return meta.Configuration, report(), err
+	}
+	if err := ctx.Err(); err != nil {
+		return meta.Configuration, report(), fmt.Errorf("parsers: %w", err)
+	}
+
+	files, err := ro.listFiles(ro.tmplDir, *ro.destdir)
+	if err != nil {
+		return meta.Configuration, report(), err
+	}
+	if files, err = ro.expandFiles(files, meta); err != nil {
+		return meta.Configuration, report(), err
+	}
+	if err := ro.handleFiles(ctx, files, meta); err != nil {
+		return meta.Configuration, report(), err
+	}
+
+	if ro.lock {
+		kept, err := ro.removeOrphans(ctx, meta.Configuration, files, rec.changes)
+		if err != nil {
+			return meta.Configuration, report(), err
+		}
+		content, err := ro.newLock(rec.changes, kept).encode()
+		if err != nil {
+			return meta.Configuration, report(), fmt.Errorf("lock: %w", err)
+		}
+		if err := WriteFile(ctx, filepath.Join(*ro.destdir, craft.LockFile), content, cfs.RwRR); err != nil {
+			return meta.Configuration, report(), fmt.Errorf("write lock: %w", err)
+		}
+	}
+
+	if err := rec.commit(); err != nil {
+		return meta.Configuration, report(), fmt.Errorf("commit changes: %w", err)
+	}
+	if err := runHooks(ctx, *ro.destdir, "post", hooks.Post); err != nil {
+		return meta.Configuration, report(), err
+	}
+	return meta.Configuration, report(), nil
+}
+
+// Parse executes all parsers given in options, ordered by their dependencies (see ParserNode),
+// and returns the resulting Metadata with the name of the parser having set each field (see Report Provenance).
+//
+// Nothing is generated and files written or removed by parsers aren't applied on destination directory (as with WithDryRun).
+// As such, handlers aren't required.
+func Parse(parent context.Context, config craft.Configuration, opts ...RunOption) (Metadata, map[string]string, error) {
+	meta := newMetadata(config)
+
+	ro, err := newRunOpt(append(opts, withoutHandlers())...)
+	if err != nil {
+		return meta, nil, fmt.Errorf("parse run options: %w", err)
+	}
+	rec := &recorder{destdir: *ro.destdir, dryRun: true, out: ro.output}
+	ctx := context.WithValue(parent, loggerKey, ro.logger)
+	ctx = context.WithValue(ctx, recorderKey, rec)
+
+	provenance, err := runParsers(ctx, *ro.destdir, ro.levels, &meta)
+	if err != nil {
+		return meta, provenance, err
+	}
+	if err := ctx.Err(); err != nil {
+		return meta, provenance, fmt.Errorf("parsers: %w", err)
+	}
+	return meta, provenance, nil
+}
+
+// ErrNotHandled is the error returned by Render when none of the handlers handles the template.
+var ErrNotHandled = errors.New("template isn't handled by any handler")
+
+// Render renders the single template tmpl with metadata as Run would generate it
+// (user patches and managed regions aside) and returns its content.
+//
+// Invalid content (see templating.Validate) is still returned (with a warning) to help fixing the template.
+//
+// tmpl is the template path relative to templates directory (see WithTemplates), with or without craft.TmplExtension
+// (e.g. ".github/workflows/ci.yml.tmpl"). Its handler is the first one handling it, as in Run,
+// however it's rendered even when the handler states it shouldn't be generated or should be removed.
+//
+// Templates whose path is templated (see expandFiles) are rendered with their first expanded path.
+//
+// Parsers aren't executed, as such Parse result or fake metadata can be given.
+func Render(parent context.Context, metadata Metadata, tmpl string, opts ...RunOption) ([]byte, error) {
+	ro, err := newRunOpt(append(opts, withoutParsers())...)
+	if err != nil {
+		return nil, fmt.Errorf("parse run options: %w", err)
+	}
+	ctx := context.WithValue(parent, loggerKey, ro.logger)
+
+	rel := strings.TrimSuffix(path.Clean(filepath.ToSlash(tmpl)), craft.TmplExtension)
+	src := path.Join(ro.tmplDir, rel+craft.TmplExtension)
+	if _, err := fs.Stat(ro.fs, src); err != nil {
+		return nil, fmt.Errorf("template '%s': %w", rel+craft.TmplExtension, err)
+	}
+
+	// templated paths are rendered with their first expansion (see expandFiles)
+	var data any = metadata
+	if isTemplated(rel) {
+		paths, err := ro.expandPath(rel, metadata)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", rel+craft.TmplExtension, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("'%s': %w: no path produced for this project", rel+craft.TmplExtension, ErrInvalidPath)
+		}
+		if len(paths) > 1 {
+			GetLogger(ctx).Warnf("'%s' produces %d files, only '%s' is rendered", rel+craft.TmplExtension, len(paths), paths[0].rel)
+		}
+		rel = paths[0].rel
+		if paths[0].data != nil {
+			data = paths[0].data
+		}
+	}
+	dest := filepath.Join(*ro.destdir, filepath.FromSlash(rel))
+	name := path.Base(rel)
+
+	var ok bool
+	var handler Handler
+	var result HandlerResult
+	for _, handler = range ro.handlers {
+		if result, ok = handler(src, dest, name); ok {
+			break
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("'%s': %w", rel+craft.TmplExtension, ErrNotHandled)
+	}
+	shouldRemove, err := result.remove(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", name, err)
+	}
+	shouldGenerate, err := result.generate(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", name, err)
+	}
+	switch {
+	case shouldRemove:
+		GetLogger(ctx).Warnf("'%s' (handled by '%s') would be removed from this project by generation", name, funcName(handler))
+	case result.disabled(metadata) || !shouldGenerate:
+		GetLogger(ctx).Warnf("'%s' (handled by '%s') wouldn't be generated for this project by generation", name, funcName(handler))
+	default:
+	}
+
+	parsed, err := ro.parse(src, result)
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err := parsed.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("template execute: %w", err)
+	}
+	content := ro.finalize(result, src, dest, rendered.Bytes())
+	if err := result.validate(dest, content); err != nil {
+		GetLogger(ctx).Warnf("'%s' would fail generation: %s", name, err.Error()) // still returned to help fixing the template
+	}
+	return content, nil
+}
+
+// newMetadata returns the initial Metadata given to parsers.
+func newMetadata(config craft.Configuration) Metadata {
+	return Metadata{
 		Configuration: config,
 		Languages:     map[string]any{},
 		Clis:          map[string]struct{}{},
@@ -31,32 +228,156 @@ func Run(parent context.Context, config craft.Configuration, opts ...RunOption)
 		Jobs:          map[string]struct{}{},
 		Workers:       map[string]struct{}{},
 	}
+}
 
-	ro, err := newRunOpt(opts...)
-	if err != nil {
-		return meta.Configuration, fmt.Errorf("parse run options: %w", err)
+// hooks returns config hooks to run during generation.
+//
+// Hooks aren't run (and as such none are returned) with WithDryRun
+// or when the output isn't destination directory (see WithOutputFS) since they would modify it.
+func (ro *runOptions) hooks(ctx context.Context, config craft.Configuration) craft.Hooks {
+	if config.Hooks == nil {
+		return craft.Hooks{}
 	}
-	ctx := context.WithValue(parent, loggerKey, ro.logger)
+	if _, ok := ro.output.(osOutput); ro.dryRun || !ok {
+		GetLogger(ctx).Infof("not running hooks since destination directory isn't modified")
+		return craft.Hooks{}
+	}
+	return *config.Hooks
+}
+
+// removeOrphans removes generated files present in previous lock file
+// whose template doesn't exist anymore (e.g. a template removed or renamed between two craft versions)
+// or doesn't produce them anymore (e.g. a templated path whose item was removed from metadata, see expandFiles).
+//
+// Files modified manually since last generation are kept (they're owned by users when they don't have the generated header anymore),
+// as well as files excluded in craft configuration.
+//
+// Lock entries of files not handled during this run but whose template still exists are returned to be kept in the new lock.
+func (ro *runOptions) removeOrphans(ctx context.Context, config craft.Configuration, files []file, changes []Change) (map[string]LockEntry, error) {
+	expanded := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		expanded[lockKey(*ro.destdir, f.dest)] = struct{}{}
+	}
+	produced := make(map[string]struct{}, len(changes))
+	for _, change := range changes {
+		if change.Src != "" {
+			produced[lockKey(*ro.destdir, change.Dest)] = struct{}{}
+		}
+	}
+
+	kept := map[string]LockEntry{}
+	keys := slices.Sorted(maps.Keys(ro.previous.Files))
+	errs := make([]error, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := produced[key]; ok || config.IsExcluded(key) {
+			continue
+		}
+		entry := ro.previous.Files[key]
 
-	errs := make([]error, 0, len(ro.parsers))
-	for _, parser := range ro.parsers {
-		if parser == nil {
+		// a template still existing is only orphaned when its path is templated and doesn't expand to this file anymore
+		reason := "template doesn't exist anymore"
+		if _, err := fs.Stat(ro.fs, path.Join(ro.tmplDir, entry.Template)); !errors.Is(err, fs.ErrNotExist) {
+			if _, ok := expanded[key]; ok || !isTemplated(entry.Template) {
+				kept[key] = entry
+				continue
+			}
+			reason = "template doesn't produce it anymore"
+		}
+		target, symlink := readlink(ro.output, key)
+		content := []byte(target)
+		if !symlink {
+			var err error
+			if content, err = fs.ReadFile(ro.output, key); err != nil {
+				continue // doesn't exist anymore (or isn't a file)
+			}
+		}
+		unchanged := Checksum(content) == entry.Checksum
+		if !symlink && !unchanged && !isGenerated(content) {
+			GetLogger(ctx).Infof("not removing '%s' since its %s but it isn't generated by craft (user owned)", key, reason)
+			continue
+		}
+		if !unchanged {
+			GetLogger(ctx).Warnf("not removing '%s' even if its %s: %s", key, reason, ReasonModified)
 			continue
 		}
-		errs = append(errs, parser(ctx, *ro.destdir, &meta))
+
+		GetLogger(ctx).Infof("removing '%s' since its %s ('%s')", key, reason, entry.Template)
+		change := Change{Dest: filepath.Join(*ro.destdir, filepath.FromSlash(key)), Reason: reason}
+		if err := remove(ctx, change, removeFile); err != nil {
+			errs = append(errs, fmt.Errorf("remove orphan '%s': %w", key, err))
+		}
 	}
-	if err := errors.Join(errs...); err != nil {
-		return meta.Configuration, err
+	return kept, errors.Join(errs...)
+}
+
+// newLock computes the new Lock from the changes done (or to be done) by handlers.
+//
+// Entries of skipped files are kept as is (as long as they exist)
+// and entries of files whose content didn't change keep their version.
+//
+// User owned files with managed regions aren't tracked since a locked file is a generated one (see IsGenerated).
+//
+// kept entries are the ones of files not handled during this run but whose template still exists (see removeOrphans).
+func (ro *runOptions) newLock(changes []Change, kept map[string]LockEntry) Lock {
+	lock := Lock{Files: map[string]LockEntry{}}
+	for key, entry := range kept {
+		if _, err := fs.Stat(ro.output, key); err == nil {
+			lock.Files[key] = entry
+		}
 	}
-	return meta.Configuration, ro.handleDir(ctx, ro.tmplDir, *ro.destdir, meta)
+	for _, change := range changes {
+		if change.Src == "" || change.Reason == ReasonRegions {
+			continue // parsers files and user owned files aren't tracked
+		}
+		key := lockKey(*ro.destdir, change.Dest)
+		previous, ok := ro.previous.Files[key]
+
+		switch change.Action {
+		case ActionCreate, ActionUpdate, ActionUnchanged:
+			entry := LockEntry{
+				Checksum: Checksum(change.Content),
+				Template: strings.TrimPrefix(change.Src, ro.tmplDir+"/"),
+				Version:  ro.version,
+			}
+			if ok && previous.Checksum == entry.Checksum {
+				entry.Version = previous.Version
+			}
+			lock.Files[key] = entry
+		case ActionSkip:
+			// keep track of skipped files (user owned or modified manually) as long as they exist
+			if _, err := fs.Stat(ro.output, key); ok && err == nil {
+				lock.Files[key] = previous
+			}
+		case ActionRemove:
+		}
+	}
+	return lock
 }
 
-func (ro *runOptions) handleDir(ctx context.Context, srcdir, destdir string, metadata Metadata) error {
+// ReasonExcluded is the Change reason of files skipped because they match one of craft.Configuration Exclude globs.
+const ReasonExcluded = "excluded in " + craft.File
+
+// ReasonModified is the Change reason of generated files skipped
+// because they were modified manually since last generation (see WithLock).
+const ReasonModified = "modified manually since last generation (checksum differs from lock file)"
+
+// file is a template file to handle with its destination path.
+type file struct {
+	// data is the data given to the template execution when it isn't Metadata (see expandFiles).
+	data any
+
+	src  string
+	dest string
+}
+
+// listFiles returns all template files (with their destination) in srcdir and its subdirectories.
+func (ro *runOptions) listFiles(srcdir, destdir string) ([]file, error) {
 	entries, err := ro.fs.ReadDir(srcdir)
 	if err != nil {
-		return fmt.Errorf("read directory: %w", err)
+		return nil, fmt.Errorf("read directory: %w", err)
 	}
 
+	var files []file
 	errs := make([]error, 0, len(entries))
 	for _, entry := range entries {
 		src := path.Join(srcdir, entry.Name())
@@ -64,63 +385,238 @@ func (ro *runOptions) handleDir(ctx context.Context, srcdir, destdir string, met
 
 		// handler directories
 		if entry.IsDir() {
-			errs = append(errs, ro.handleDir(ctx, src, dest, metadata)) // NOTE should handlers also tune directories generation ?
+			children, err := ro.listFiles(src, dest) // NOTE should handlers also tune directories generation ?
+			files = append(files, children...)
+			errs = append(errs, err)
 			continue
 		}
 
 		// handle files
 		if !strings.HasSuffix(src, craft.TmplExtension) || // ignore NOT suffixed files with .tmpl
 			strings.HasSuffix(src, craft.PartExtension+craft.TmplExtension) || // ignore suffixed files with .part.tmpl
-			strings.HasSuffix(src, craft.PatchExtension+craft.TmplExtension) { // ignore suffixed files with .patch.tmpl
+			strings.HasSuffix(src, craft.PatchExtension+craft.TmplExtension) { // ignore suffixed files with .patch.tmpl (patches are user owned in destination directory)
 			continue //nolint:whitespace
 		}
-
-		dest = strings.TrimSuffix(dest, craft.TmplExtension)
-		errs = append(errs, ro.handleFile(ctx, src, dest, metadata))
+		files = append(files, file{src: src, dest: strings.TrimSuffix(dest, craft.TmplExtension)})
 	}
-	return errors.Join(errs...)
+	return files, errors.Join(errs...)
 }
 
-func (ro *runOptions) handleFile(ctx context.Context, src, dest string, metadata Metadata) error {
+func (ro *runOptions) handleFile(ctx context.Context, f file, metadata Metadata) error {
+	start := time.Now()
+	src, dest := f.src, f.dest
 	name := filepath.Base(dest)
 
+	// templates whose path is expanded are given the current item alongside metadata (see PathData)
+	data := f.data
+	if data == nil {
+		data = metadata
+	}
+
 	// find the right handler for current file
 	var ok bool
+	var handler Handler
 	var result HandlerResult
-	for _, h := range ro.handlers {
-		if result, ok = h(src, dest, name); ok {
+	for _, handler = range ro.handlers {
+		if result, ok = handler(src, dest, name); ok {
 			break
 		}
 	}
 	if !ok {
 		return nil // no handler defined for this file, skipping it
 	}
+	base := Change{Dest: dest, Handler: funcName(handler), Src: src}
+	rel := lockKey(*ro.destdir, dest)
 
-	// remove file in case result is asking it
-	if result.ShouldRemove != nil && result.ShouldRemove(metadata) {
-		if err := os.RemoveAll(dest); err != nil && !os.IsNotExist(err) {
+	// excluded files are never written nor removed
+	if metadata.IsExcluded(rel) {
+		GetLogger(ctx).Infof("not generating '%s': %s", name, ReasonExcluded)
+		base.Action, base.Duration, base.Reason = ActionSkip, time.Since(start), ReasonExcluded
+		return getRecorder(ctx).record(base, nil)
+	}
+	forced := metadata.IsForced(rel)
+
+	// remove file in case result is asking it (even when forced since it's disabled by configuration)
+	shouldRemove, err := result.remove(metadata)
+	if err != nil {
+		return fmt.Errorf("'%s': %w", name, err)
+	}
+	if shouldRemove {
+		base.Duration = time.Since(start)
+		if err := remove(ctx, base, OutputFS.RemoveAll); err != nil {
 			GetLogger(ctx).Warnf("failed to delete '%s': %s", name, err.Error())
 		}
 		return nil
 	}
 
-	// avoid generating file if it already exists or something else
-	if result.ShouldGenerate != nil && !result.ShouldGenerate(metadata) {
-		GetLogger(ctx).Infof("not generating '%s' since it already exists", name)
-		return nil
+	// avoid generating file if it's disabled, already exists or something else
+	disabled := result.disabled(metadata)
+	shouldGenerate, err := result.generate(metadata)
+	if err != nil {
+		return fmt.Errorf("'%s': %w", name, err)
+	}
+	if disabled || !forced && !shouldGenerate {
+		reason := "disabled for this project"
+		if existing, err := fs.ReadFile(ro.output, rel); !disabled && err == nil && !ro.isGenerated(rel, existing) {
+			// only generate managed regions of user owned files
+			if hasRegions(existing) {
+				return ro.handleRegions(ctx, base, result, data, existing, start)
+			}
+			reason = "already exists and isn't generated by craft (user owned)"
+		}
+		GetLogger(ctx).Infof("not generating '%s': %s", name, reason)
+		base.Action, base.Duration, base.Reason = ActionSkip, time.Since(start), reason
+		return getRecorder(ctx).record(base, nil)
 	}
 
 	// template source file and generate it in target directory
+	tmpl, err := ro.parse(src, result)
+	if err != nil {
+		return err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return fmt.Errorf("template execute: %w", err)
+	}
+
+	// apply user patch (if any) on top of rendered template
+	content, err := patchFile(ctx, dest, rendered.Bytes())
+	if err != nil {
+		return err
+	}
+	content = ro.finalize(result, src, dest, content)
+	if err := result.validate(dest, content); err != nil {
+		return fmt.Errorf("'%s': %w", name, err)
+	}
+	if result.Symlink {
+		if _, ok := ro.output.(SymlinkFS); !ok {
+			return fmt.Errorf("'%s': symbolic links aren't supported by output", name)
+		}
+		if err := checkSymlink(rel, string(content)); err != nil {
+			return err
+		}
+	}
+
+	change := newChange(ro.output, rel, src, dest, content, result.mode(dest))
+	change.Duration, change.Handler = time.Since(start), base.Handler
+
+	// avoid overriding generated file modified manually since last generation
+	if entry, ok := ro.previous.Files[rel]; ok && !forced && change.Action == ActionUpdate && Checksum(change.Previous) != entry.Checksum {
+		GetLogger(ctx).Warnf("not generating '%s': %s, remove it or save your edits in a patch file to generate it again", name, ReasonModified)
+		base.Action, base.Duration, base.Reason = ActionSkip, time.Since(start), ReasonModified
+		return getRecorder(ctx).record(base, nil)
+	}
+
+	if err := getRecorder(ctx).record(change, func() error { return writeFile(ro.output, rel, change) }); err != nil {
+		return fmt.Errorf("write '%s': %w", name, err)
+	}
+	return nil
+}
+
+// isGenerated returns truthy if content of rel destination file is the one of a generated file,
+// i.e. it has the generated header or it's in previous lock file with the same checksum (see IsGenerated).
+func (ro *runOptions) isGenerated(rel string, content []byte) bool {
+	entry, ok := ro.previous.Files[rel]
+	return isGenerated(content) || ok && entry.Checksum == Checksum(content)
+}
+
+// ReasonRegions is the Change reason of user owned files where only managed regions are generated.
+const ReasonRegions = "user owned, only managed regions are generated"
+
+// handleRegions generates the managed regions of existing user owned base destination file.
+//
+// Each region is replaced with its named template (defined with "define" go template statement) execution,
+// or with the whole template execution when the region isn't named.
+//
+// data is the data given to the template execution, i.e. Metadata or PathData for expanded paths (see expandFiles).
+func (ro *runOptions) handleRegions(ctx context.Context, base Change, result HandlerResult, data any, existing []byte, start time.Time) error {
+	name := filepath.Base(base.Dest)
+	tmpl, err := ro.parse(base.Src, result)
+	if err != nil {
+		return err
+	}
+
+	content, err := mergeRegions(existing, func(region string) ([]byte, error) {
+		var rendered bytes.Buffer
+		if region == "" {
+			err = tmpl.Execute(&rendered, data)
+		} else {
+			err = tmpl.ExecuteTemplate(&rendered, region, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("template execute: %w", err)
+		}
+		return rendered.Bytes(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("'%s': %w", name, err)
+	}
+	if err := result.validate(base.Dest, content); err != nil {
+		return fmt.Errorf("'%s': %w", name, err)
+	}
+
+	rel := lockKey(*ro.destdir, base.Dest)
+	change := newChange(ro.output, rel, base.Src, base.Dest, content, result.mode(base.Dest))
+	change.Duration, change.Handler, change.Reason = time.Since(start), base.Handler, ReasonRegions
+	GetLogger(ctx).Debugf("generating '%s' managed regions only since it's user owned", name)
+
+	if err := getRecorder(ctx).record(change, func() error { return writeFile(ro.output, rel, change) }); err != nil {
+		return fmt.Errorf("write '%s': %w", name, err)
+	}
+	return nil
+}
+
+// finalize returns the final content of dest from its rendered content (user patch applied),
+// i.e. with the generated header of dest (see IsGenerated) unless result NoHeader is truthy
+// and with result line endings (see LineEnding and FinalNewline).
+//
+// The header holds the craft version (see WithVersion) and src template.
+// When content didn't change since last generation (see WithLock), the previous version is kept
+// to avoid rewriting all generated files at each craft upgrade.
+//
+// Symbolic links content is their target, as such it's only trimmed.
+func (ro *runOptions) finalize(result HandlerResult, src, dest string, content []byte) []byte {
+	if result.Symlink {
+		return bytes.TrimSpace(content)
+	}
+	template := strings.TrimPrefix(src, ro.tmplDir+"/")
+	if isTemplated(template) {
+		template = "" // avoid go template expressions in generated files (e.g. helm templates)
+	}
+	final := func(version string) []byte {
+		if result.NoHeader {
+			return result.LineEnding.normalize(content, result.FinalNewline)
+		}
+		return result.LineEnding.normalize(withHeader(content, header(dest, version, template)), result.FinalNewline)
+	}
+
+	rel := lockKey(*ro.destdir, dest)
+	if entry, ok := ro.previous.Files[rel]; ok && entry.Version != ro.version && !result.NoHeader {
+		previous := final(entry.Version)
+		if existing, err := fs.ReadFile(ro.output, rel); err == nil && bytes.Equal(existing, previous) {
+			return previous
+		}
+	}
+	return final(ro.version)
+}
+
+// parse parses src template file alongside all result globs with result delimiters.
+func (ro *runOptions) parse(src string, result HandlerResult) (*template.Template, error) {
 	tmpl, err := template.New(path.Base(src)).
-		Funcs(sprig.FuncMap()).
-		Funcs(templating.FuncMap()).
+		Funcs(ro.funcs).
 		Delims(result.StartDelim, result.EndDelim).
 		ParseFS(ro.fs, result.Globs...)
 	if err != nil {
-		return fmt.Errorf("parse template file(s): %w", err)
+		return nil, fmt.Errorf("parse template file(s): %w", err)
 	}
-	if err := templating.Execute(tmpl, metadata, dest); err != nil {
-		return fmt.Errorf("template execute: %w", err)
+	return tmpl, nil
+}
+
+// funcName returns the function name of the input handler or parser with its package name (e.g. "handler.Makefile").
+func funcName(function any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(function).Pointer())
+	if fn == nil {
+		return ""
 	}
-	return nil
+	return path.Base(fn.Name())
 }
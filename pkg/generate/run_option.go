@@ -3,15 +3,21 @@ package generate
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
 	"os"
+	"text/template"
 
+	"github.com/Masterminds/sprig/v3"
 	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
 	"github.com/kilianpaquier/cli-sdk/pkg/clog"
+
+	"github.com/kilianpaquier/craft/pkg/templating"
 )
 
 var (
 	// ErrMissingHandlers is returned when WithHandlers isn't used
-	// or the input slice of handlers is empty.
+	// or the input slice of handlers is empty (and templates directory doesn't have a craft.ManifestFile declaring some).
 	//
 	// The error is specified since it could be ignored in case of dynamic handlers.
 	//
@@ -19,7 +25,7 @@ var (
 	// mean that the generation would do nothing since templates are only generated if an handler is associated to.
 	ErrMissingHandlers = errors.New("missing handlers, nothing would be generated")
 
-	// ErrMissingParsers is returned when WithParsers isn't used
+	// ErrMissingParsers is returned when neither WithParsers nor WithParserNodes are used
 	// or the input slice of parsers is empty.
 	//
 	// The error is specified since it could be ignored in case of dynamic parsers.
@@ -29,12 +35,27 @@ var (
 // RunOption is the right function to tune Run function with specific behaviors.
 type RunOption func(runOptions) runOptions
 
-// WithParsers specifies the slice of parsers.
+// WithParsers specifies the slice of parsers, run one after the other in given order.
 //
 // To know more about parsers, please check Parser type documentation.
+// See WithParserNodes to declare parsers dependencies and run independent ones concurrently.
 func WithParsers(parsers ...Parser) RunOption {
 	return func(ro runOptions) runOptions {
-		ro.parsers = parsers
+		ro.parsers = make([]ParserNode, 0, len(parsers))
+		for _, parser := range parsers {
+			ro.parsers = append(ro.parsers, ParserNode{Parser: parser})
+		}
+		return ro
+	}
+}
+
+// WithParserNodes specifies the slice of parsers with the Metadata fields they consume and provide.
+//
+// It overrides parsers given with WithParsers (and the other way around).
+// To know more about parsers ordering, please check ParserNode type documentation.
+func WithParserNodes(nodes ...ParserNode) RunOption {
+	return func(ro runOptions) runOptions {
+		ro.parsers = nodes
 		return ro
 	}
 }
@@ -42,6 +63,7 @@ func WithParsers(parsers ...Parser) RunOption {
 // WithHandlers defines the slice of handlers to use during generation.
 //
 // To know more about handlers, please check Handler type documentation.
+// Note that handlers declared in templates directory craft.ManifestFile take precedence over them.
 func WithHandlers(handlers ...Handler) RunOption {
 	return func(ro runOptions) runOptions {
 		ro.handlers = handlers
@@ -65,6 +87,8 @@ func WithDestination(destdir string) RunOption {
 // and not the one OS specific from filepath.Join.
 //
 // If not given, default filesystem is the embedded one FS.
+//
+// In case dir contains a craft.ManifestFile, its declared handlers are used (see WithHandlers).
 func WithTemplates(dir string, fs cfs.FS) RunOption {
 	return func(ro runOptions) runOptions {
 		ro.tmplDir = dir
@@ -73,6 +97,86 @@ func WithTemplates(dir string, fs cfs.FS) RunOption {
 	}
 }
 
+// WithTemplateLayers specifies templates layers (see TemplateLayer) on top of templates filesystem (see WithTemplates).
+//
+// Each layer can override or add templates files (including parts with .part.tmpl),
+// files from last layers taking precedence over the ones from first layers and templates filesystem.
+// Layers not existing are ignored.
+//
+// Note that added templates files (not only parts) still need an Handler to be generated.
+func WithTemplateLayers(layers ...cfs.FS) RunOption {
+	return func(ro runOptions) runOptions {
+		ro.layers = append(ro.layers, layers...)
+		return ro
+	}
+}
+
+// WithOutputFS specifies the filesystem where generated files are written (or removed).
+//
+// If not given, default output is OSOutput on destination directory (see WithDestination).
+// See also MemoryOutput, TarOutput and ZipOutput.
+func WithOutputFS(output OutputFS) RunOption {
+	return func(ro runOptions) runOptions {
+		ro.output = output
+		return ro
+	}
+}
+
+// WithDryRun specifies that Run must not modify anything in destination directory.
+//
+// All parsers and handlers are still executed and templates rendered
+// but the resulting changes are only returned by Run (with their rendered content) instead of being applied.
+func WithDryRun() RunOption {
+	return func(ro runOptions) runOptions {
+		ro.dryRun = true
+		return ro
+	}
+}
+
+// WithPartialOutput specifies that Run must apply changes as soon as they're computed
+// instead of staging them until all parsers and handlers succeeded.
+//
+// With it, a failing parser or template leaves the destination directory partially generated.
+func WithPartialOutput() RunOption {
+	return func(ro runOptions) runOptions {
+		ro.partial = true
+		return ro
+	}
+}
+
+// WithLock specifies that Run must read and write the lock file (craft.LockFile) in destination directory.
+//
+// The lock file keeps track of every file generated by an handler with its template, craft version (see WithVersion) and checksum.
+// It allows Run to know whether a generated file was modified manually since last generation
+// (even if it still has the generated header) and in that case to skip it instead of overriding manual edits.
+func WithLock() RunOption {
+	return func(ro runOptions) runOptions {
+		ro.lock = true
+		return ro
+	}
+}
+
+// WithVersion specifies the craft version written in generated files header and lock file entries (see WithLock).
+func WithVersion(version string) RunOption {
+	return func(ro runOptions) runOptions {
+		ro.version = version
+		return ro
+	}
+}
+
+// WithWorkers specifies the maximum number of template files handled (rendered) concurrently.
+//
+// Handlers given with WithHandlers (and their ShouldGenerate and ShouldRemove functions)
+// must then be safe for concurrent use (e.g. craft ones in handler package).
+//
+// If not given (or lower than 1), files are handled one at a time (e.g. runtime.GOMAXPROCS can be given to use all CPUs).
+func WithWorkers(workers int) RunOption {
+	return func(ro runOptions) runOptions {
+		ro.workers = workers
+		return ro
+	}
+}
+
 // WithLogger specifies the logger to use during generation.
 //
 // If not given, default logger is clog.Noop.
@@ -83,14 +187,45 @@ func WithLogger(log clog.Logger) RunOption {
 	}
 }
 
+// withoutHandlers doesn't require handlers (see ErrMissingHandlers) when only parsers are run (see Parse).
+func withoutHandlers() RunOption {
+	return func(ro runOptions) runOptions {
+		ro.noHandlers = true
+		return ro
+	}
+}
+
+// withoutParsers doesn't require parsers (see ErrMissingParsers) when they aren't run (see Render).
+func withoutParsers() RunOption {
+	return func(ro runOptions) runOptions {
+		ro.noParsers = true
+		return ro
+	}
+}
+
 // runOptions is the struct related to Option function(s) defining all optional properties.
 type runOptions struct {
 	handlers []Handler
-	parsers  []Parser
+	parsers  []ParserNode
+	levels   [][]ParserNode // parsers ordered by dependencies, computed from parsers
+
+	noHandlers bool // handlers aren't required (see withoutHandlers)
+	noParsers  bool // parsers aren't required (see withoutParsers)
 
 	destdir *string
+	dryRun  bool
+	output  OutputFS
+	partial bool
+
+	funcs   template.FuncMap // computed once for all templates
+	workers int
+
+	lock     bool
+	previous Lock // previous lock file content, only read when lock is truthy
+	version  string
 
 	fs      cfs.FS
+	layers  []cfs.FS
 	tmplDir string
 
 	logger clog.Logger
@@ -106,28 +241,61 @@ func newRunOpt(opts ...RunOption) (runOptions, error) {
 		}
 	}
 
+	if ro.destdir == nil {
+		dir, _ := os.Getwd()
+		ro.destdir = &dir
+	}
+	if ro.workers < 1 {
+		ro.workers = 1
+	}
+	if ro.output == nil {
+		ro.output = OSOutput(*ro.destdir)
+	}
+	if ro.fs == nil {
+		ro.fs = FS()
+		ro.tmplDir = "_templates"
+	}
+	if len(ro.layers) > 0 {
+		ro.fs = overlayFS{root: ro.tmplDir, layers: append([]cfs.FS{TemplateLayer(ro.tmplDir, ro.fs)}, ro.layers...)}
+	}
+
+	// templates manifest handlers take precedence over given ones
+	manifest, err := readManifest(ro.fs, ro.tmplDir)
+	if err != nil {
+		return runOptions{}, err
+	}
+	ro.handlers = append(manifest, ro.handlers...)
+
 	errs := make([]error, 0, 2)
-	if len(ro.parsers) == 0 {
+	if len(ro.parsers) == 0 && !ro.noParsers {
 		errs = append(errs, ErrMissingParsers)
 	}
-	if len(ro.handlers) == 0 {
+	if len(ro.handlers) == 0 && !ro.noHandlers {
 		errs = append(errs, ErrMissingHandlers)
 	}
 	if err := errors.Join(errs...); err != nil {
 		return runOptions{}, err
 	}
 
-	if ro.destdir == nil {
-		dir, _ := os.Getwd()
-		ro.destdir = &dir
+	nodes := make([]ParserNode, 0, len(ro.parsers))
+	for _, node := range ro.parsers {
+		if node.Parser == nil {
+			continue
+		}
+		if node.Name == "" {
+			node.Name = funcName(node.Parser)
+		}
+		nodes = append(nodes, node)
 	}
-	if ro.fs == nil {
-		ro.fs = FS()
-		ro.tmplDir = "_templates"
+	if ro.levels, err = parserLevels(nodes); err != nil {
+		return runOptions{}, fmt.Errorf("parsers: %w", err)
 	}
+
 	if ro.logger == nil {
 		ro.logger = clog.Noop()
 	}
+	ro.funcs = sprig.FuncMap()
+	maps.Copy(ro.funcs, templating.FuncMap())
 	return ro, nil
 }
 
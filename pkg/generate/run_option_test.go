@@ -36,6 +36,28 @@ func TestRunOption(t *testing.T) {
 		assert.Equal(t, "dest", *ro.destdir)
 	})
 
+	t.Run("success_dry_run", func(t *testing.T) {
+		// Arrange
+		f := WithDryRun()
+
+		// Act
+		ro := f(runOptions{})
+
+		// Assert
+		assert.True(t, ro.dryRun)
+	})
+
+	t.Run("success_lock", func(t *testing.T) {
+		// Arrange
+		f := WithLock()
+
+		// Act
+		ro := f(runOptions{})
+
+		// Assert
+		assert.True(t, ro.lock)
+	})
+
 	t.Run("success_logger", func(t *testing.T) {
 		// Arrange
 		logger := clog.Std()
@@ -73,6 +95,51 @@ func TestRunOption(t *testing.T) {
 		assert.Equal(t, cfs.OS(), ro.fs)
 	})
 
+	t.Run("success_output", func(t *testing.T) {
+		// Arrange
+		out := MemoryOutput()
+		f := WithOutputFS(out)
+
+		// Act
+		ro := f(runOptions{})
+
+		// Assert
+		assert.Equal(t, out, ro.output)
+	})
+
+	t.Run("success_partial_output", func(t *testing.T) {
+		// Arrange
+		f := WithPartialOutput()
+
+		// Act
+		ro := f(runOptions{})
+
+		// Assert
+		assert.True(t, ro.partial)
+	})
+
+	t.Run("success_version", func(t *testing.T) {
+		// Arrange
+		f := WithVersion("v1.0.0")
+
+		// Act
+		ro := f(runOptions{})
+
+		// Assert
+		assert.Equal(t, "v1.0.0", ro.version)
+	})
+
+	t.Run("success_workers", func(t *testing.T) {
+		// Arrange
+		f := WithWorkers(4)
+
+		// Act
+		ro := f(runOptions{})
+
+		// Assert
+		assert.Equal(t, 4, ro.workers)
+	})
+
 	t.Run("success_defaults", func(t *testing.T) {
 		// Arrange
 		pwd, _ := os.Getwd()
@@ -92,5 +159,6 @@ func TestRunOption(t *testing.T) {
 		assert.Equal(t, expected.fs, ro.fs)
 		assert.Equal(t, expected.logger, ro.logger)
 		assert.Equal(t, expected.tmplDir, ro.tmplDir)
+		assert.Equal(t, 1, ro.workers)
 	})
 }
@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/jarcoal/httpmock"
@@ -22,6 +28,7 @@ import (
 	"github.com/kilianpaquier/craft/pkg/generate"
 	"github.com/kilianpaquier/craft/pkg/generate/handler"
 	"github.com/kilianpaquier/craft/pkg/generate/parser"
+	"github.com/kilianpaquier/craft/pkg/templating"
 )
 
 func TestRun_Error(t *testing.T) {
@@ -29,7 +36,7 @@ func TestRun_Error(t *testing.T) {
 
 	t.Run("error_missing_handlers_parsers", func(t *testing.T) {
 		// Act
-		_, err := generate.Run(ctx, craft.Configuration{})
+		_, _, err := generate.Run(ctx, craft.Configuration{})
 
 		// Assert
 		assert.ErrorIs(t, err, generate.ErrMissingHandlers)
@@ -41,13 +48,1193 @@ func TestRun_Error(t *testing.T) {
 		parser := func(context.Context, string, *generate.Metadata) error { return errors.New("some error") }
 
 		// Act
-		_, err := generate.Run(ctx, craft.Configuration{}, generate.WithHandlers(generate.HandlerNoop), generate.WithParsers(parser))
+		_, _, err := generate.Run(ctx, craft.Configuration{}, generate.WithHandlers(generate.HandlerNoop), generate.WithParsers(parser))
 
 		// Assert
 		assert.ErrorContains(t, err, "some error")
 	})
 }
 
+func TestRun_Atomic(t *testing.T) {
+	ctx := context.Background()
+
+	templates := fstest.MapFS{
+		"a.txt.tmpl": &fstest.MapFile{Data: []byte("a")},
+		"b.txt.tmpl": &fstest.MapFile{Data: []byte("{{ .Invalid }}")},
+	}
+	all := func(src, _, _ string) (generate.HandlerResult, bool) {
+		return generate.HandlerResult{Globs: []string{src}}, true
+	}
+	opts := func(destdir string) []generate.RunOption {
+		return []generate.RunOption{
+			generate.WithDestination(destdir),
+			generate.WithHandlers(all),
+			generate.WithParsers(generate.ParserNoop),
+			generate.WithTemplates(".", templates),
+		}
+	}
+
+	t.Run("error_nothing_written", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, opts(destdir)...)
+
+		// Assert
+		assert.ErrorContains(t, err, "template execute")
+		assert.NoFileExists(t, filepath.Join(destdir, "a.txt"))
+	})
+
+	t.Run("error_partial_output", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, append(opts(destdir), generate.WithPartialOutput())...)
+
+		// Assert
+		assert.ErrorContains(t, err, "template execute")
+		assert.FileExists(t, filepath.Join(destdir, "a.txt"))
+	})
+}
+
+func TestRun_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	info := func(_ context.Context, _ string, metadata *generate.Metadata) error {
+		metadata.ProjectHost = "github.com"
+		metadata.ProjectName = "craft"
+		metadata.ProjectPath = "kilianpaquier/craft"
+		return nil
+	}
+
+	t.Run("success_nothing_written", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		config := craft.Configuration{
+			CI:          &craft.CI{Name: craft.GitHub, Release: &craft.Release{}},
+			Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}},
+			NoChart:     true,
+			Platform:    craft.GitHub,
+		}
+
+		// Act
+		_, report, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithDryRun(),
+			generate.WithHandlers(handler.Defaults()...),
+			generate.WithParserNodes(parser.Defaults(generate.ParserNode{Parser: info})...))
+
+		// Assert
+		require.NoError(t, err)
+		entries, err := os.ReadDir(destdir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		require.NotEmpty(t, report.Changes)
+		for _, change := range report.Changes {
+			assert.Equal(t, generate.ActionCreate, change.Action)
+			assert.NotEmpty(t, change.Content)
+		}
+	})
+
+	t.Run("success_actions", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		config := craft.Configuration{
+			Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}},
+			NoChart:     true,
+			Platform:    craft.GitHub,
+		}
+		opts := []generate.RunOption{
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Defaults()...),
+			generate.WithParserNodes(parser.Defaults(generate.ParserNode{Parser: info})...),
+		}
+		_, _, err := generate.Run(ctx, config, opts...)
+		require.NoError(t, err)
+
+		// README.md is user owned once generated and .gitignore was modified
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, ".gitignore"), []byte("# Code generated by craft; DO NOT EDIT.\n\n*.out"), cfs.RwRR))
+		// .releaserc.yml must be removed since there's no release configured
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, ".releaserc.yml"), []byte("# Code generated by craft; DO NOT EDIT."), cfs.RwRR))
+
+		// Act
+		_, report, err := generate.Run(ctx, config, append(opts, generate.WithDryRun())...)
+
+		// Assert
+		require.NoError(t, err)
+		actions := map[string]generate.Action{}
+		handlers := map[string]string{}
+		for _, change := range report.Changes {
+			rel, err := filepath.Rel(destdir, change.Dest)
+			require.NoError(t, err)
+			actions[rel] = change.Action
+			handlers[rel] = change.Handler
+		}
+		assert.Positive(t, report.Duration)
+		assert.Equal(t, "handler.Makefile", handlers["Makefile"])
+		assert.Equal(t, "handler.Readme", handlers["README.md"])
+		assert.Equal(t, generate.ActionUpdate, actions[".gitignore"])
+		assert.Equal(t, generate.ActionRemove, actions[".releaserc.yml"])
+		assert.Equal(t, generate.ActionSkip, actions["README.md"])
+		assert.Equal(t, generate.ActionUnchanged, actions["Makefile"])
+		assert.FileExists(t, filepath.Join(destdir, ".releaserc.yml"))
+	})
+}
+
+func TestRun_Output(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success_memory", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		out := generate.MemoryOutput()
+		config := craft.Configuration{
+			Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}},
+			NoChart:     true,
+		}
+
+		// Act
+		_, _, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Makefile),
+			generate.WithLock(),
+			generate.WithOutputFS(out),
+			generate.WithParsers(generate.ParserNoop))
+
+		// Assert
+		require.NoError(t, err)
+		entries, err := os.ReadDir(destdir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		for _, name := range []string{"Makefile", "scripts/craft.mk", craft.LockFile} {
+			_, err := fs.Stat(out, name)
+			assert.NoError(t, err, name)
+		}
+	})
+
+	t.Run("success_memory_skip_reason", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "file.txt"), []byte("on disk"), cfs.RwRR))
+		templates := fstest.MapFS{"templates/file.txt.tmpl": &fstest.MapFile{Data: []byte("content")}}
+		disabled := func(src, _, _ string) (generate.HandlerResult, bool) {
+			return generate.HandlerResult{Globs: []string{src}, ShouldGenerate: func(generate.Metadata) bool { return false }}, true
+		}
+
+		// Act
+		_, report, err := generate.Run(ctx, craft.Configuration{},
+			generate.WithDestination(destdir),
+			generate.WithHandlers(disabled),
+			generate.WithOutputFS(generate.MemoryOutput()),
+			generate.WithParsers(generate.ParserNoop),
+			generate.WithTemplates("templates", templates))
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, report.Changes, 1)
+		assert.Equal(t, "disabled for this project", report.Changes[0].Reason) // file only exists on disk, not in output
+	})
+}
+
+func TestRun_Patch(t *testing.T) {
+	ctx := context.Background()
+
+	config := craft.Configuration{
+		Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}},
+		NoChart:     true,
+	}
+
+	t.Run("error_conflict", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		patch := "@@ -1,3 +1,4 @@\n # Code generated by craft; DO NOT EDIT.\n \n-include ./scripts/*.mak\n+include ./scripts/*.mk\n+include ./local.mk\n"
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "Makefile.patch"), []byte(patch), cfs.RwRR))
+
+		// Act
+		_, _, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Makefile),
+			generate.WithParsers(generate.ParserNoop))
+
+		// Assert
+		assert.ErrorIs(t, err, generate.ErrPatchConflict)
+		assert.ErrorContains(t, err, "apply patch 'Makefile.patch': hunk #1 (@@ -1,3 +1,4 @@)")
+		assert.NoFileExists(t, filepath.Join(destdir, "Makefile"))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		patch := "--- a/Makefile\n+++ b/Makefile\n@@ -1,3 +1,4 @@\n # Code generated by craft; DO NOT EDIT.\n \n" +
+			"-include ./scripts/*.mk\n\\ No newline at end of file\n+include ./scripts/*.mk\n+include ./local.mk\n"
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "Makefile.patch"), []byte(patch), cfs.RwRR))
+
+		// Act
+		_, _, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Makefile),
+			generate.WithParsers(generate.ParserNoop))
+
+		// Assert
+		require.NoError(t, err)
+		bytes, err := os.ReadFile(filepath.Join(destdir, "Makefile"))
+		require.NoError(t, err)
+		assert.Equal(t, "# Code generated by craft from Makefile.tmpl; DO NOT EDIT.\n\ninclude ./scripts/*.mk\ninclude ./local.mk\n", string(bytes))
+	})
+}
+
+func TestRun_Validation(t *testing.T) {
+	ctx := context.Background()
+
+	templates := fstest.MapFS{
+		"config.yml.tmpl": &fstest.MapFile{Data: []byte("key: value\nother: [\n")},
+	}
+	opts := func(destdir string, noValidation bool) []generate.RunOption {
+		all := func(src, _, _ string) (generate.HandlerResult, bool) {
+			return generate.HandlerResult{Globs: []string{src}, NoValidation: noValidation}, true
+		}
+		return []generate.RunOption{
+			generate.WithDestination(destdir),
+			generate.WithHandlers(all),
+			generate.WithParsers(generate.ParserNoop),
+			generate.WithTemplates(".", templates),
+		}
+	}
+
+	t.Run("error_invalid_output", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, opts(destdir, false)...)
+
+		// Assert
+		assert.ErrorIs(t, err, templating.ErrInvalidOutput)
+		assert.ErrorContains(t, err, "'config.yml': invalid output, not a valid YAML file: line 4 (other: [)")
+		assert.NoFileExists(t, filepath.Join(destdir, "config.yml"))
+	})
+
+	t.Run("success_no_validation", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, opts(destdir, true)...)
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destdir, "config.yml"))
+	})
+}
+
+func TestRun_Header(t *testing.T) {
+	ctx := context.Background()
+
+	templates := fstest.MapFS{
+		"templates/README.md.tmpl":      &fstest.MapFile{Data: []byte("# Project\n")},
+		"templates/config.json.tmpl":    &fstest.MapFile{Data: []byte("{}\n")},
+		"templates/config.yml.tmpl":     &fstest.MapFile{Data: []byte("\nkey: value\n")},
+		"templates/launcher.sh.tmpl":    &fstest.MapFile{Data: []byte("#!/bin/sh\necho hello\n")},
+		"templates/legacy.sh.tmpl":      &fstest.MapFile{Data: []byte("#!/bin/sh\n# Code generated by craft; DO NOT EDIT.\n\necho legacy\n")},
+		"templates/renovate.json5.tmpl": &fstest.MapFile{Data: []byte("{}\n")},
+	}
+	all := func(src, dest, name string) (generate.HandlerResult, bool) {
+		return generate.HandlerResult{
+			Globs:          []string{src},
+			NoHeader:       name == "README.md",
+			ShouldGenerate: func(generate.Metadata) bool { return name == "README.md" || generate.IsGenerated(dest) },
+		}, true
+	}
+	run := func(destdir, version string) (generate.Report, error) {
+		_, report, err := generate.Run(ctx, craft.Configuration{},
+			generate.WithDestination(destdir),
+			generate.WithHandlers(all),
+			generate.WithLock(),
+			generate.WithParsers(generate.ParserNoop),
+			generate.WithTemplates("templates", templates),
+			generate.WithVersion(version))
+		return report, err
+	}
+	action := func(t *testing.T, report generate.Report, name string) generate.Action {
+		t.Helper()
+		index := slices.IndexFunc(report.Changes, func(change generate.Change) bool { return filepath.Base(change.Dest) == name })
+		require.NotEqual(t, -1, index)
+		return report.Changes[index].Action
+	}
+	read := func(t *testing.T, destdir, name string) string {
+		t.Helper()
+		bytes, err := os.ReadFile(filepath.Join(destdir, name))
+		require.NoError(t, err)
+		return string(bytes)
+	}
+
+	t.Run("success_generated", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		_, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "# Code generated by craft v1.0.0 from config.yml.tmpl; DO NOT EDIT.\n\nkey: value\n", read(t, destdir, "config.yml"))
+		assert.Equal(t, "#!/bin/sh\n# Code generated by craft v1.0.0 from launcher.sh.tmpl; DO NOT EDIT.\n\necho hello\n", read(t, destdir, "launcher.sh"))
+		assert.Equal(t, "// Code generated by craft v1.0.0 from renovate.json5.tmpl; DO NOT EDIT.\n\n{}\n", read(t, destdir, "renovate.json5"))
+		assert.Equal(t, "#!/bin/sh\n# Code generated by craft v1.0.0 from legacy.sh.tmpl; DO NOT EDIT.\n\necho legacy\n", read(t, destdir, "legacy.sh")) // header written by template
+		assert.Equal(t, "{}\n", read(t, destdir, "config.json"))                                                                                         // no comment syntax
+		assert.Equal(t, "# Project\n", read(t, destdir, "README.md"))                                                                                    // NoHeader
+	})
+
+	t.Run("success_version_kept", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, err := run(destdir, "v1.0.0")
+		require.NoError(t, err)
+
+		// Act
+		_, err = run(destdir, "v1.1.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "# Code generated by craft v1.0.0 from config.yml.tmpl; DO NOT EDIT.\n\nkey: value\n", read(t, destdir, "config.yml"))
+	})
+
+	t.Run("success_no_header_locked", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, err := run(destdir, "v1.0.0")
+		require.NoError(t, err)
+
+		// Act
+		report, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, generate.ActionUnchanged, action(t, report, "config.json"))
+	})
+
+	t.Run("success_no_header_modified", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, err := run(destdir, "v1.0.0")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "config.json"), []byte(`{"user": true}`), cfs.RwRR))
+
+		// Act
+		report, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, generate.ActionSkip, action(t, report, "config.json"))
+		assert.JSONEq(t, `{"user": true}`, read(t, destdir, "config.json"))
+	})
+}
+
+func TestRun_Files(t *testing.T) {
+	ctx := context.Background()
+
+	templates := fstest.MapFS{
+		"templates/hooks/pre-commit.tmpl": &fstest.MapFile{Data: []byte("#!/bin/sh\nmake lint\n\n\n")},
+		"templates/install.bat.tmpl":      &fstest.MapFile{Data: []byte("@echo off\r\necho {{ .ProjectName }}\n")},
+		"templates/latest.tmpl":           &fstest.MapFile{Data: []byte("  releases/{{ .ProjectName }}\n")},
+	}
+	handle := func(src, _, name string) (generate.HandlerResult, bool) {
+		result := generate.HandlerResult{Globs: []string{src}}
+		switch name {
+		case "pre-commit":
+			result.FinalNewline, result.Mode = true, cfs.RwxRxRxRx
+		case "install.bat":
+			result.LineEnding = generate.LineEndingCRLF
+		case "latest":
+			result.Symlink = true
+		}
+		return result, true
+	}
+	parser := func(_ context.Context, _ string, metadata *generate.Metadata) error {
+		metadata.ProjectName = "craft"
+		return nil
+	}
+	opts := func(destdir string) []generate.RunOption {
+		return []generate.RunOption{
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handle),
+			generate.WithLock(),
+			generate.WithParsers(parser),
+			generate.WithTemplates("templates", templates),
+		}
+	}
+
+	t.Run("success_written", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, opts(destdir)...)
+
+		// Assert
+		require.NoError(t, err)
+
+		hook, err := os.ReadFile(filepath.Join(destdir, "hooks", "pre-commit"))
+		require.NoError(t, err)
+		assert.Equal(t, "#!/bin/sh\nmake lint\n", string(hook))
+		info, err := os.Stat(filepath.Join(destdir, "hooks", "pre-commit"))
+		require.NoError(t, err)
+		assert.Equal(t, cfs.RwxRxRxRx, info.Mode().Perm())
+
+		bat, err := os.ReadFile(filepath.Join(destdir, "install.bat"))
+		require.NoError(t, err)
+		assert.Equal(t, "@echo off\r\necho craft\r\n", string(bat))
+
+		target, err := os.Readlink(filepath.Join(destdir, "latest"))
+		require.NoError(t, err)
+		assert.Equal(t, "releases/craft", target)
+	})
+
+	t.Run("success_symlink_unchanged", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, _, err := generate.Run(ctx, craft.Configuration{}, opts(destdir)...)
+		require.NoError(t, err)
+
+		// Act
+		_, report, err := generate.Run(ctx, craft.Configuration{}, opts(destdir)...)
+
+		// Assert
+		require.NoError(t, err)
+		index := slices.IndexFunc(report.Changes, func(change generate.Change) bool { return filepath.Base(change.Dest) == "latest" })
+		require.NotEqual(t, -1, index)
+		assert.Equal(t, generate.ActionUnchanged, report.Changes[index].Action)
+	})
+
+	t.Run("success_file_replaced", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "latest"), []byte("content"), cfs.RwRR))
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, opts(destdir)...)
+
+		// Assert
+		require.NoError(t, err)
+		target, err := os.Readlink(filepath.Join(destdir, "latest"))
+		require.NoError(t, err)
+		assert.Equal(t, "releases/craft", target)
+	})
+
+	t.Run("success_memory", func(t *testing.T) {
+		// Arrange
+		out := generate.MemoryOutput()
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, append(opts(t.TempDir()), generate.WithOutputFS(out))...)
+
+		// Assert
+		require.NoError(t, err)
+		symlinks, ok := out.(generate.SymlinkFS)
+		require.True(t, ok)
+		target, err := symlinks.Readlink("latest")
+		require.NoError(t, err)
+		assert.Equal(t, "releases/craft", target)
+	})
+
+	for name, target := range map[string]string{
+		"error_symlink_parent":   "../outside",
+		"error_symlink_absolute": "/etc",
+		"error_symlink_escape":   "releases/../../outside",
+		"error_symlink_empty":    "  ",
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			destdir := t.TempDir()
+			templates := fstest.MapFS{"templates/latest.tmpl": &fstest.MapFile{Data: []byte(target)}}
+
+			// Act
+			_, _, err := generate.Run(ctx, craft.Configuration{},
+				generate.WithDestination(destdir),
+				generate.WithHandlers(handle),
+				generate.WithParsers(generate.ParserNoop),
+				generate.WithTemplates("templates", templates))
+
+			// Assert
+			assert.ErrorIs(t, err, generate.ErrUnsafeSymlink)
+			_, err = os.Lstat(filepath.Join(destdir, "latest"))
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+		})
+	}
+
+	t.Run("error_archive", func(t *testing.T) {
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{}, append(opts(t.TempDir()), generate.WithOutputFS(generate.TarOutput(io.Discard)))...)
+
+		// Assert
+		assert.ErrorContains(t, err, "'latest': symbolic links aren't supported by output")
+	})
+}
+
+func TestRun_PathTemplating(t *testing.T) {
+	ctx := context.Background()
+
+	all := func(src, _, _ string) (generate.HandlerResult, bool) {
+		return generate.HandlerResult{Globs: []string{src}}, true
+	}
+	run := func(destdir string, templates fstest.MapFS, clis ...string) error {
+		parser := func(_ context.Context, _ string, metadata *generate.Metadata) error {
+			metadata.ProjectName = "craft"
+			metadata.Clis = make(map[string]struct{}, len(clis))
+			for _, cli := range clis {
+				metadata.Clis[cli] = struct{}{}
+			}
+			return nil
+		}
+		_, _, err := generate.Run(ctx, craft.Configuration{},
+			generate.WithDestination(destdir),
+			generate.WithHandlers(all),
+			generate.WithLock(),
+			generate.WithParsers(parser),
+			generate.WithTemplates("templates", templates))
+		return err
+	}
+	templates := fstest.MapFS{
+		"templates/cmd/{{ each .Clis }}/Dockerfile.tmpl":             &fstest.MapFile{Data: []byte("COPY {{ .Name }} /{{ .ProjectName }}\n")},
+		"templates/{{ if gt (len .Clis) 2 }}many.txt{{ end }}.tmpl":  &fstest.MapFile{Data: []byte("many")},
+		"templates/{{ .ProjectName }}/{{ each .Clis }}.txt.tmpl":     &fstest.MapFile{Data: []byte("{{ .Item }}")},
+		"templates/{{ if .Clis }}static.txt{{ else }}{{ end }}.tmpl": &fstest.MapFile{Data: []byte("static")},
+	}
+
+	t.Run("success_expanded", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		err := run(destdir, templates, "second", "first")
+
+		// Assert
+		require.NoError(t, err)
+		for _, cli := range []string{"first", "second"} {
+			dockerfile, err := os.ReadFile(filepath.Join(destdir, "cmd", cli, "Dockerfile"))
+			require.NoError(t, err)
+			assert.Equal(t, "# Code generated by craft; DO NOT EDIT.\n\nCOPY "+cli+" /craft\n", string(dockerfile))
+			assert.FileExists(t, filepath.Join(destdir, "craft", cli+".txt"))
+		}
+		assert.FileExists(t, filepath.Join(destdir, "static.txt"))
+		assert.NoFileExists(t, filepath.Join(destdir, "many.txt")) // empty path
+	})
+
+	t.Run("success_orphans_removed", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, run(destdir, templates, "first", "second"))
+
+		// Act
+		err := run(destdir, templates, "first")
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destdir, "cmd", "first", "Dockerfile"))
+		assert.NoFileExists(t, filepath.Join(destdir, "cmd", "second", "Dockerfile"))
+	})
+
+	t.Run("success_empty_collection", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		err := run(destdir, templates)
+
+		// Assert
+		require.NoError(t, err)
+		entries, err := os.ReadDir(destdir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, craft.LockFile, entries[0].Name())
+	})
+
+	for name, tmpl := range map[string]string{
+		"error_each_twice":     "templates/{{ each .Clis }}/{{ each .Clis }}.txt.tmpl",
+		"error_not_collection": "templates/{{ each .ProjectName }}.txt.tmpl",
+		"error_outside":        `templates/{{ each .Clis }}/{{ print ".." }}/file.txt.tmpl`,
+		"error_duplicate":      "templates/{{ each .Clis | len }}.txt.tmpl",
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			templates := fstest.MapFS{tmpl: &fstest.MapFile{Data: []byte("content")}}
+
+			// Act
+			err := run(t.TempDir(), templates, "a", "b")
+
+			// Assert
+			assert.ErrorIs(t, err, generate.ErrInvalidPath)
+		})
+	}
+}
+
+func TestRun_Lock(t *testing.T) {
+	ctx := context.Background()
+
+	config := craft.Configuration{
+		Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}},
+		NoChart:     true,
+	}
+
+	run := func(destdir, version string) ([]generate.Change, error) {
+		_, report, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Makefile),
+			generate.WithLock(),
+			generate.WithParsers(generate.ParserNoop),
+			generate.WithVersion(version))
+		return report.Changes, err
+	}
+
+	t.Run("success_written", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		_, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		makefile, err := os.ReadFile(filepath.Join(destdir, "Makefile"))
+		require.NoError(t, err)
+		lock, err := generate.ReadLock(destdir)
+		require.NoError(t, err)
+		expected := generate.LockEntry{Checksum: generate.Checksum(makefile), Template: "Makefile.tmpl", Version: "v1.0.0"}
+		assert.Equal(t, expected, lock.Files["Makefile"])
+		assert.Contains(t, lock.Files, "scripts/craft.mk")
+	})
+
+	t.Run("success_version_kept", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, err := run(destdir, "v1.0.0")
+		require.NoError(t, err)
+
+		// Act
+		_, err = run(destdir, "v1.1.0")
+
+		// Assert
+		require.NoError(t, err)
+		lock, err := generate.ReadLock(destdir)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", lock.Files["Makefile"].Version)
+	})
+
+	t.Run("success_modified_skipped", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, err := run(destdir, "v1.0.0")
+		require.NoError(t, err)
+		before, err := generate.ReadLock(destdir)
+		require.NoError(t, err)
+
+		modified := "# Code generated by craft; DO NOT EDIT.\n\ninclude ./local.mk\n"
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "Makefile"), []byte(modified), cfs.RwRR))
+
+		// Act
+		changes, err := run(destdir, "v1.1.0")
+
+		// Assert
+		require.NoError(t, err)
+		index := slices.IndexFunc(changes, func(change generate.Change) bool { return filepath.Base(change.Dest) == "Makefile" })
+		require.NotEqual(t, -1, index)
+		assert.Equal(t, generate.ActionSkip, changes[index].Action)
+		assert.Contains(t, changes[index].Reason, "modified manually")
+
+		bytes, err := os.ReadFile(filepath.Join(destdir, "Makefile"))
+		require.NoError(t, err)
+		assert.Equal(t, modified, string(bytes))
+
+		after, err := generate.ReadLock(destdir)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	orphan := func(t *testing.T, content, checksum string) string {
+		t.Helper()
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "old.yml"), []byte(content), cfs.RwRR))
+		lock := "files:\n  old.yml:\n    checksum: " + checksum + "\n    template: old.yml.tmpl\n"
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, craft.LockFile), []byte(lock), cfs.RwRR))
+		return destdir
+	}
+
+	t.Run("success_orphan_removed", func(t *testing.T) {
+		// Arrange
+		content := "# Code generated by craft; DO NOT EDIT.\n"
+		destdir := orphan(t, content, generate.Checksum([]byte(content)))
+
+		// Act
+		changes, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(destdir, "old.yml"))
+		assert.Contains(t, changes, generate.Change{
+			Action:   generate.ActionRemove,
+			Dest:     filepath.Join(destdir, "old.yml"),
+			Previous: []byte(content),
+			Reason:   "template doesn't exist anymore",
+		})
+		lock, err := generate.ReadLock(destdir)
+		require.NoError(t, err)
+		assert.NotContains(t, lock.Files, "old.yml")
+	})
+
+	t.Run("success_orphan_no_header_removed", func(t *testing.T) {
+		// Arrange
+		content := "key: value\n"
+		destdir := orphan(t, content, generate.Checksum([]byte(content)))
+
+		// Act
+		_, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(destdir, "old.yml"))
+	})
+
+	t.Run("success_orphan_user_owned", func(t *testing.T) {
+		// Arrange
+		destdir := orphan(t, "some user content\n", generate.Checksum([]byte("previous")))
+
+		// Act
+		_, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destdir, "old.yml"))
+	})
+
+	t.Run("success_orphan_modified", func(t *testing.T) {
+		// Arrange
+		destdir := orphan(t, "# Code generated by craft; DO NOT EDIT.\n", generate.Checksum([]byte("previous")))
+
+		// Act
+		_, err := run(destdir, "v1.0.0")
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destdir, "old.yml"))
+	})
+
+	t.Run("success_template_exists_not_handled", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		templates := fstest.MapFS{"templates/file.txt.tmpl": &fstest.MapFile{Data: []byte("content")}}
+		run := func(handled bool) error {
+			handler := func(src, _, _ string) (generate.HandlerResult, bool) {
+				return generate.HandlerResult{Globs: []string{src}}, handled
+			}
+			_, _, err := generate.Run(ctx, craft.Configuration{},
+				generate.WithDestination(destdir),
+				generate.WithHandlers(handler),
+				generate.WithLock(),
+				generate.WithParsers(generate.ParserNoop),
+				generate.WithTemplates("templates", templates))
+			return err
+		}
+		require.NoError(t, run(true))
+		before, err := generate.ReadLock(destdir)
+		require.NoError(t, err)
+
+		// Act
+		err = run(false)
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destdir, "file.txt"))
+		after, err := generate.ReadLock(destdir)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+}
+
+func TestRun_ExcludeForce(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(destdir string, config craft.Configuration) ([]generate.Change, error) {
+		config.Maintainers = []*craft.Maintainer{{Name: "kilianpaquier"}}
+		_, report, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Makefile),
+			generate.WithLock(),
+			generate.WithParsers(generate.ParserNoop))
+		return report.Changes, err
+	}
+
+	t.Run("success_excluded", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+
+		// Act
+		changes, err := run(destdir, craft.Configuration{Exclude: []string{"scripts/*.mk"}})
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destdir, "Makefile"))
+		assert.NoDirExists(t, filepath.Join(destdir, "scripts"))
+		for _, change := range changes {
+			if filepath.Base(filepath.Dir(change.Dest)) == "scripts" {
+				assert.Equal(t, generate.ActionSkip, change.Action)
+				assert.Equal(t, generate.ReasonExcluded, change.Reason)
+			}
+		}
+	})
+
+	t.Run("success_excluded_not_removed", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, err := run(destdir, craft.Configuration{})
+		require.NoError(t, err)
+
+		// Act
+		_, err = run(destdir, craft.Configuration{Exclude: []string{"scripts"}, NoMakefile: true})
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(destdir, "Makefile"))
+		assert.FileExists(t, filepath.Join(destdir, "scripts", "craft.mk"))
+	})
+
+	t.Run("success_excluded_parsers", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "LICENSE"), []byte("user license"), cfs.RwRR))
+		require.NoError(t, os.MkdirAll(filepath.Join(destdir, "chart"), cfs.RwxRxRxRx))
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "chart", "values.yaml"), []byte("user: values\n"), cfs.RwRR))
+		parser := func(ctx context.Context, destdir string, _ *generate.Metadata) error {
+			if err := generate.WriteFile(ctx, filepath.Join(destdir, "LICENSE"), []byte("license"), cfs.RwRR); err != nil {
+				return err
+			}
+			return generate.RemoveAll(ctx, filepath.Join(destdir, "chart"))
+		}
+
+		// Act
+		_, report, err := generate.Run(ctx, craft.Configuration{Exclude: []string{"LICENSE", "chart/values.yaml"}},
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Makefile),
+			generate.WithParsers(parser))
+
+		// Assert
+		require.NoError(t, err)
+		license, err := os.ReadFile(filepath.Join(destdir, "LICENSE"))
+		require.NoError(t, err)
+		assert.Equal(t, "user license", string(license))
+		assert.FileExists(t, filepath.Join(destdir, "chart", "values.yaml"))
+		assert.Contains(t, report.Changes, generate.Change{Action: generate.ActionSkip, Dest: filepath.Join(destdir, "LICENSE"), Reason: generate.ReasonExcluded})
+	})
+
+	t.Run("success_forced", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "Makefile"), []byte("include ./local.mk\n"), cfs.RwRR))
+
+		// Act
+		_, err := run(destdir, craft.Configuration{Force: []string{"Makefile"}})
+
+		// Assert
+		require.NoError(t, err)
+		assert.True(t, generate.IsGenerated(filepath.Join(destdir, "Makefile")))
+	})
+
+	t.Run("success_forced_removed", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		_, err := run(destdir, craft.Configuration{})
+		require.NoError(t, err)
+
+		// Act
+		_, err = run(destdir, craft.Configuration{Force: []string{"Makefile", "scripts"}, NoMakefile: true})
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(destdir, "Makefile"))
+		assert.NoFileExists(t, filepath.Join(destdir, "scripts", "craft.mk"))
+	})
+
+	t.Run("success_not_forced", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, "Makefile"), []byte("include ./local.mk\n"), cfs.RwRR))
+
+		// Act
+		_, err := run(destdir, craft.Configuration{})
+
+		// Assert
+		require.NoError(t, err)
+		assert.False(t, generate.IsGenerated(filepath.Join(destdir, "Makefile")))
+	})
+}
+
+func TestRun_Regions(t *testing.T) {
+	ctx := context.Background()
+
+	config := craft.Configuration{Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}}}
+	run := func(destdir string) ([]generate.Change, error) {
+		_, report, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Git),
+			generate.WithLock(),
+			generate.WithParsers(generate.ParserNoop))
+		return report.Changes, err
+	}
+
+	t.Run("success_regions_generated", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		gitignore := filepath.Join(destdir, ".gitignore")
+		require.NoError(t, os.WriteFile(gitignore, []byte("/local\n\n# craft:begin golang\n# craft:end\n\n/other\n"), cfs.RwRR))
+
+		// Act
+		changes, err := run(destdir)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, changes, 2) // .gitignore and lock file
+		assert.Equal(t, generate.ActionUpdate, changes[0].Action)
+		assert.Equal(t, generate.ReasonRegions, changes[0].Reason)
+
+		content, err := os.ReadFile(gitignore)
+		require.NoError(t, err)
+		assert.False(t, generate.IsGenerated(gitignore))
+		assert.True(t, strings.HasPrefix(string(content), "/local\n\n# craft:begin golang\n# If you prefer the allow list template"))
+		assert.True(t, strings.HasSuffix(string(content), "\n# craft:end\n\n/other\n"))
+	})
+
+	t.Run("success_user_edits_kept", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		gitignore := filepath.Join(destdir, ".gitignore")
+		require.NoError(t, os.WriteFile(gitignore, []byte("# craft:begin golang\n# craft:end\n"), cfs.RwRR))
+		_, err := run(destdir)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(gitignore)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(gitignore, append([]byte("/local\n"), content...), cfs.RwRR))
+
+		// Act
+		changes, err := run(destdir)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, generate.ActionUnchanged, changes[0].Action)
+	})
+
+	t.Run("success_disabled_not_generated", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		readme := filepath.Join(destdir, "README.md")
+		content := "# craft\n\n<!-- craft:begin -->\n<!-- craft:end -->\n"
+		require.NoError(t, os.WriteFile(readme, []byte(content), cfs.RwRR))
+
+		// Act
+		_, report, err := generate.Run(ctx, craft.Configuration{NoReadme: true},
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Readme),
+			generate.WithParsers(generate.ParserNoop))
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, report.Changes, 1)
+		assert.Equal(t, generate.ActionSkip, report.Changes[0].Action)
+		assert.Equal(t, "disabled for this project", report.Changes[0].Reason)
+		bytes, err := os.ReadFile(readme)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(bytes))
+	})
+
+	t.Run("error_invalid_region", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(destdir, ".gitignore"), []byte("# craft:begin golang\n"), cfs.RwRR))
+
+		// Act
+		_, err := run(destdir)
+
+		// Assert
+		assert.ErrorIs(t, err, generate.ErrInvalidRegion)
+	})
+}
+
+func TestRun_Hooks(t *testing.T) {
+	ctx := context.Background()
+
+	run := func(destdir string, hooks craft.Hooks, opts ...generate.RunOption) error {
+		config := craft.Configuration{Hooks: &hooks, Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}}}
+		opts = append(opts,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Makefile),
+			generate.WithParsers(generate.ParserNoop))
+		_, _, err := generate.Run(ctx, config, opts...)
+		return err
+	}
+
+	t.Run("success_pre_post", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(destdir, "sub"), cfs.RwxRxRxRx))
+		hooks := craft.Hooks{
+			Post: []craft.Hook{{Command: "test -f Makefile && echo post > post.txt"}},
+			Pre:  []craft.Hook{{Command: `echo "$NAME" > pre.txt`, Dir: "sub", Env: map[string]string{"NAME": "pre"}}},
+		}
+
+		// Act
+		err := run(destdir, hooks)
+
+		// Assert
+		require.NoError(t, err)
+		pre, err := os.ReadFile(filepath.Join(destdir, "sub", "pre.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "pre\n", string(pre))
+		assert.FileExists(t, filepath.Join(destdir, "post.txt"))
+	})
+
+	t.Run("error_fail", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		hooks := craft.Hooks{Pre: []craft.Hook{{Command: "exit 1"}}}
+
+		// Act
+		err := run(destdir, hooks)
+
+		// Assert
+		assert.ErrorContains(t, err, "pre hook 'exit 1': exit status 1")
+		assert.NoFileExists(t, filepath.Join(destdir, "Makefile"))
+	})
+
+	t.Run("success_warn", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		hooks := craft.Hooks{Post: []craft.Hook{{Command: "exit 1", OnError: craft.HookWarn}}}
+
+		// Act
+		err := run(destdir, hooks)
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(destdir, "Makefile"))
+	})
+
+	t.Run("error_timeout", func(t *testing.T) {
+		// Arrange
+		hooks := craft.Hooks{Pre: []craft.Hook{{Command: "sleep 5", Timeout: 10 * time.Millisecond}}}
+
+		// Act
+		err := run(t.TempDir(), hooks)
+
+		// Assert
+		assert.ErrorContains(t, err, "pre hook 'sleep 5': timeout after 10ms")
+	})
+
+	t.Run("success_not_run_dry_run", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		hooks := craft.Hooks{Pre: []craft.Hook{{Command: "touch pre.txt"}}}
+
+		// Act
+		err := run(destdir, hooks, generate.WithDryRun())
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(destdir, "pre.txt"))
+	})
+}
+
+func TestRun_TemplateLayers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success_part_overridden", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		layer := fstest.MapFS{
+			"templates/.gitignore-golang.part.tmpl": &fstest.MapFile{Data: []byte(`{{- define "golang" }}` + "\n\n# Custom golang\n*.out" + `{{- end }}`)},
+		}
+		golang := func(_ context.Context, _ string, metadata *generate.Metadata) error {
+			metadata.Languages["golang"] = nil
+			return nil
+		}
+
+		// Act
+		_, _, err := generate.Run(ctx, craft.Configuration{},
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Git),
+			generate.WithParsers(golang),
+			generate.WithTemplateLayers(generate.TemplateLayer("templates", layer), generate.TemplateLayer("missing", layer)))
+
+		// Assert
+		require.NoError(t, err)
+		bytes, err := os.ReadFile(filepath.Join(destdir, ".gitignore"))
+		require.NoError(t, err)
+		assert.Equal(t, "# Code generated by craft from .gitignore.tmpl; DO NOT EDIT.\n\n# Custom golang\n*.out", string(bytes))
+	})
+}
+
+func TestRun_Workers(t *testing.T) {
+	info := func(_ context.Context, _ string, metadata *generate.Metadata) error {
+		metadata.ProjectHost = "github.com"
+		metadata.ProjectName = "craft"
+		metadata.ProjectPath = "kilianpaquier/craft"
+		return nil
+	}
+	config := craft.Configuration{
+		CI:          &craft.CI{Name: craft.GitHub, Release: &craft.Release{}},
+		Maintainers: []*craft.Maintainer{{Name: "kilianpaquier"}},
+		Platform:    craft.GitHub,
+	}
+
+	t.Run("error_cancelled", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		_, _, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithHandlers(handler.Defaults()...),
+			generate.WithParserNodes(parser.Defaults(generate.ParserNode{Parser: info})...))
+
+		// Assert
+		assert.ErrorIs(t, err, context.Canceled)
+		entries, err := os.ReadDir(destdir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("success_deterministic", func(t *testing.T) {
+		// Arrange
+		ctx := context.Background()
+		run := func(workers int) []string {
+			_, report, err := generate.Run(ctx, config,
+				generate.WithDestination(t.TempDir()),
+				generate.WithDryRun(),
+				generate.WithHandlers(handler.Defaults()...),
+				generate.WithParserNodes(parser.Defaults(generate.ParserNode{Parser: info})...),
+				generate.WithWorkers(workers))
+			require.NoError(t, err)
+
+			srcs := make([]string, 0, len(report.Changes))
+			for _, change := range report.Changes {
+				srcs = append(srcs, change.Src)
+			}
+			return srcs
+		}
+		expected := run(1)
+
+		// Act
+		actual := run(8)
+
+		// Assert
+		assert.Equal(t, expected, actual)
+	})
+}
+
 func TestRun_NoLang(t *testing.T) {
 	httpClient := cleanhttp.DefaultClient()
 	httpmock.ActivateNonDefault(httpClient)
@@ -80,7 +1267,7 @@ func TestRun_NoLang(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info})...)
 			})
 		}
 	})
@@ -98,7 +1285,7 @@ func TestRun_NoLang(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info})...)
 			})
 		}
 	})
@@ -122,7 +1309,7 @@ func TestRun_NoLang(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info})...)
 			})
 		}
 	})
@@ -156,7 +1343,7 @@ func TestRun_Golang(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info, golang)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info}, generate.ParserNode{Parser: golang})...)
 			})
 		}
 	})
@@ -176,7 +1363,7 @@ func TestRun_Golang(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info, golang)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info}, generate.ParserNode{Parser: golang})...)
 			})
 		}
 	})
@@ -202,7 +1389,7 @@ func TestRun_Golang(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info, golang)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info}, generate.ParserNode{Parser: golang})...)
 			})
 		}
 	})
@@ -244,7 +1431,7 @@ func TestRun_Hugo(t *testing.T) {
 			}
 
 			// Act & Assert
-			test(ctx, t, config, parser.Defaults(info, hugo)...)
+			test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info}, generate.ParserNode{Parser: hugo})...)
 		})
 	}
 }
@@ -275,7 +1462,7 @@ func TestRun_Node(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info, node)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info}, generate.ParserNode{Parser: node})...)
 			})
 		}
 	})
@@ -296,7 +1483,7 @@ func TestRun_Node(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info, node)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info}, generate.ParserNode{Parser: node})...)
 			})
 		}
 	})
@@ -329,14 +1516,125 @@ func TestRun_Node(t *testing.T) {
 				}
 
 				// Act & Assert
-				test(ctx, t, config, parser.Defaults(info, node)...)
+				test(ctx, t, config, parser.Defaults(generate.ParserNode{Parser: info}, generate.ParserNode{Parser: node})...)
+			})
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success_without_handlers", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		parser := generate.ParserNode{
+			Name:     "info",
+			Provides: []string{"Languages.golang", "ProjectName"},
+			Parser: func(ctx context.Context, destdir string, metadata *generate.Metadata) error {
+				metadata.Languages["golang"] = nil
+				metadata.ProjectName = "craft"
+				return generate.WriteFile(ctx, filepath.Join(destdir, "file.txt"), []byte("content"), cfs.RwRR)
+			},
+		}
+
+		// Act
+		metadata, provenance, err := generate.Parse(ctx, craft.Configuration{}, generate.WithDestination(destdir), generate.WithParserNodes(parser))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "craft", metadata.ProjectName)
+		assert.Equal(t, map[string]any{"golang": nil}, metadata.Languages)
+		assert.Equal(t, map[string]string{"Languages.golang": "info", "ProjectName": "info"}, provenance)
+		assert.NoFileExists(t, filepath.Join(destdir, "file.txt"))
+	})
+
+	t.Run("error_missing_parsers", func(t *testing.T) {
+		// Act
+		_, _, err := generate.Parse(ctx, craft.Configuration{}, generate.WithDestination(t.TempDir()))
+
+		// Assert
+		assert.ErrorIs(t, err, generate.ErrMissingParsers)
+		assert.NotErrorIs(t, err, generate.ErrMissingHandlers)
+	})
+}
+
+func TestRender(t *testing.T) {
+	ctx := context.Background()
+
+	templates := fstest.MapFS{
+		"templates/dir/file.txt.tmpl":       &fstest.MapFile{Data: []byte(`{{ .ProjectName }}{{ template "part" . }}`)},
+		"templates/dir/file-part.part.tmpl": &fstest.MapFile{Data: []byte(`{{ define "part" }} part{{ end }}`)},
+		"templates/ignored.txt.tmpl":        &fstest.MapFile{Data: []byte("ignored")},
+	}
+	handler := func(src, _, name string) (generate.HandlerResult, bool) {
+		if name != "file.txt" {
+			return generate.HandlerResult{}, false
+		}
+		return generate.HandlerResult{
+			Delimiter:      generate.DelimiterBracket(),
+			Globs:          []string{src, "templates/dir/*.part.tmpl"},
+			ShouldGenerate: func(generate.Metadata) bool { return false },
+		}, true
+	}
+	metadata := generate.Metadata{ProjectName: "craft"}
+	opts := []generate.RunOption{
+		generate.WithDestination(t.TempDir()),
+		generate.WithHandlers(handler),
+		generate.WithTemplates("templates", templates),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		for _, tmpl := range []string{"dir/file.txt.tmpl", "dir/file.txt"} {
+			t.Run(tmpl, func(t *testing.T) {
+				// Act
+				content, err := generate.Render(ctx, metadata, tmpl, opts...)
+
+				// Assert
+				require.NoError(t, err)
+				assert.Equal(t, "craft part", string(content))
 			})
 		}
 	})
+
+	t.Run("success_templated_path", func(t *testing.T) {
+		// Arrange
+		templates := fstest.MapFS{"templates/cmd/{{ each .Clis }}/file.txt.tmpl": &fstest.MapFile{Data: []byte("{{ .Name }}")}}
+		metadata := generate.Metadata{Clis: map[string]struct{}{"second": {}, "first": {}}}
+		all := func(src, _, _ string) (generate.HandlerResult, bool) {
+			return generate.HandlerResult{Globs: []string{src}}, true
+		}
+
+		// Act
+		content, err := generate.Render(ctx, metadata, "cmd/{{ each .Clis }}/file.txt",
+			generate.WithDestination(t.TempDir()),
+			generate.WithHandlers(all),
+			generate.WithTemplates("templates", templates))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "first", string(content))
+	})
+
+	t.Run("error_not_handled", func(t *testing.T) {
+		// Act
+		_, err := generate.Render(ctx, metadata, "ignored.txt", opts...)
+
+		// Assert
+		assert.ErrorIs(t, err, generate.ErrNotHandled)
+	})
+
+	t.Run("error_not_exists", func(t *testing.T) {
+		// Act
+		_, err := generate.Render(ctx, metadata, "missing.txt", opts...)
+
+		// Assert
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
 }
 
 // test returns the verify function for every generation verification to do.
-func test(ctx context.Context, t *testing.T, config craft.Configuration, parsers ...generate.Parser) {
+func test(ctx context.Context, t *testing.T, config craft.Configuration, parsers ...generate.ParserNode) {
 	t.Helper()
 
 	// Arrange
@@ -346,10 +1644,10 @@ func test(ctx context.Context, t *testing.T, config craft.Configuration, parsers
 	require.NoError(t, os.MkdirAll(assertdir, cfs.RwxRxRxRx))
 
 	// Act
-	_, err := generate.Run(ctx, config,
+	_, _, err := generate.Run(ctx, config,
 		generate.WithDestination(destdir),
 		generate.WithHandlers(handler.Defaults()...),
-		generate.WithParsers(parsers...))
+		generate.WithParserNodes(parsers...))
 
 	// Assert
 	require.NoError(t, err)
@@ -0,0 +1,157 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+)
+
+// staged is a change waiting to be applied at the end of Run.
+type staged struct {
+	change Change
+	apply  func() error
+}
+
+// commit applies all staged changes in their recording order.
+//
+// In case one of them fails, all already applied changes are rolled back
+// to leave the destination directory as it was before.
+func (r *recorder) commit() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	backups := make([]backup, 0, len(r.staged))
+	for _, entry := range r.staged {
+		if entry.change.Action == ActionUnchanged {
+			continue
+		}
+		out, name, err := r.resolve(entry.change.Dest)
+		if err != nil {
+			return errors.Join(err, rollback(backups))
+		}
+		b, err := newBackup(out, name)
+		if err != nil {
+			return errors.Join(fmt.Errorf("backup '%s': %w", name, err), rollback(backups))
+		}
+		backups = append(backups, b)
+
+		if err := entry.apply(); err != nil {
+			return errors.Join(fmt.Errorf("apply '%s': %w", name, err), rollback(backups))
+		}
+	}
+	r.staged = nil
+	return nil
+}
+
+// backup represents a destination (file or directory) as it was before a change.
+type backup struct {
+	out    OutputFS
+	name   string
+	exists bool
+	files  []backupFile
+}
+
+// backupFile represents a file, directory or symbolic link (content being its target) kept in a backup.
+type backupFile struct {
+	name    string
+	content []byte
+	mode    fs.FileMode
+}
+
+// newBackup keeps in memory name with all its content (recursively in case it's a directory).
+func newBackup(out OutputFS, name string) (backup, error) {
+	b := backup{out: out, name: name}
+	if target, ok := readlink(out, name); ok { // symbolic links are kept as is (even dangling ones)
+		b.exists = true
+		b.files = []backupFile{{name: name, content: []byte(target), mode: fs.ModeSymlink}}
+		return b, nil
+	}
+	if _, err := fs.Stat(out, name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return b, nil
+		}
+		return backup{}, fmt.Errorf("stat: %w", err)
+	}
+	b.exists = true
+
+	err := fs.WalkDir(out, name, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("info: %w", err)
+		}
+		file := backupFile{name: name, mode: info.Mode()}
+		switch {
+		case info.Mode().IsRegular():
+			if file.content, err = fs.ReadFile(out, name); err != nil {
+				return fmt.Errorf("read file: %w", err)
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, _ := readlink(out, name)
+			file.content = []byte(target)
+		}
+		b.files = append(b.files, file)
+		return nil
+	})
+	if err != nil {
+		return backup{}, fmt.Errorf("walk: %w", err)
+	}
+	return b, nil
+}
+
+// restore puts back the backup destination as it was when the backup was done.
+func (b backup) restore() error {
+	if err := b.out.RemoveAll(b.name); err != nil {
+		return fmt.Errorf("remove: %w", err)
+	}
+	if !b.exists {
+		return nil
+	}
+
+	for _, file := range b.files { // files are walked in lexical order, directories come before their content
+		switch {
+		case file.mode.IsDir():
+			if err := b.out.MkdirAll(file.name, file.mode.Perm()); err != nil {
+				return fmt.Errorf("create directory: %w", err)
+			}
+		case file.mode.IsRegular():
+			if err := b.out.MkdirAll(path.Dir(file.name), cfs.RwxRxRxRx); err != nil {
+				return fmt.Errorf("create directory: %w", err)
+			}
+			if err := b.out.WriteFile(file.name, file.content, file.mode.Perm()); err != nil {
+				return fmt.Errorf("write file: %w", err)
+			}
+		case file.mode&fs.ModeSymlink != 0:
+			symlinks, ok := b.out.(SymlinkFS)
+			if !ok {
+				continue // can't happen since symbolic links are only read from SymlinkFS
+			}
+			if err := b.out.MkdirAll(path.Dir(file.name), cfs.RwxRxRxRx); err != nil {
+				return fmt.Errorf("create directory: %w", err)
+			}
+			if err := symlinks.Symlink(string(file.content), file.name); err != nil {
+				return fmt.Errorf("symlink: %w", err)
+			}
+		default:
+			// other kinds of files (devices, sockets, etc.) aren't handled by craft
+		}
+	}
+	return nil
+}
+
+// rollback restores all input backups in reverse order.
+func rollback(backups []backup) error {
+	errs := make([]error, 0, len(backups))
+	for _, b := range slices.Backward(backups) {
+		if err := b.restore(); err != nil {
+			errs = append(errs, fmt.Errorf("rollback '%s': %w", b.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package generate //nolint:testpackage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_Commit(t *testing.T) {
+	write := func(t *testing.T, r *recorder, dest, content string) {
+		t.Helper()
+		out, name, err := r.resolve(dest)
+		require.NoError(t, err)
+		change := newChange(out, name, "", dest, []byte(content), cfs.RwRR)
+		require.NoError(t, r.record(change, func() error { return writeFile(out, name, change) }))
+	}
+
+	t.Run("success_staged", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		dest := filepath.Join(destdir, "file.txt")
+		r := &recorder{destdir: destdir, out: OSOutput(destdir), stage: true}
+		write(t, r, dest, "content")
+		require.NoFileExists(t, dest)
+
+		// Act
+		err := r.commit()
+
+		// Assert
+		require.NoError(t, err)
+		bytes, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, "content", string(bytes))
+	})
+
+	t.Run("error_rollback", func(t *testing.T) {
+		// Arrange
+		destdir := t.TempDir()
+		out := MemoryOutput()
+		require.NoError(t, out.WriteFile("updated.txt", []byte("previous"), cfs.RwRR))
+		require.NoError(t, out.WriteFile("dir/sub/file.txt", []byte("removed"), cfs.RwRR))
+
+		r := &recorder{destdir: destdir, out: out, stage: true}
+		write(t, r, filepath.Join(destdir, "updated.txt"), "content")
+		write(t, r, filepath.Join(destdir, "created.txt"), "content")
+		require.NoError(t, r.record(Change{Action: ActionRemove, Dest: filepath.Join(destdir, "dir")}, func() error { return out.RemoveAll("dir") }))
+		require.NoError(t, r.record(Change{Action: ActionCreate, Dest: filepath.Join(destdir, "fail")}, func() error { return errors.New("failure") }))
+
+		// Act
+		err := r.commit()
+
+		// Assert
+		assert.ErrorContains(t, err, "apply 'fail': failure")
+		bytes, err := fs.ReadFile(out, "updated.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "previous", string(bytes))
+		_, err = fs.Stat(out, "created.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		bytes, err = fs.ReadFile(out, "dir/sub/file.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "removed", string(bytes))
+	})
+}
@@ -0,0 +1,113 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/clog"
+)
+
+// handleFiles handles all input files concurrently with a bounded number of workers (see WithWorkers).
+//
+// Logs and changes of each file are buffered while it's handled and then flushed in files order,
+// making them (and returned errors) deterministic whatever the number of workers.
+//
+// Files not handled yet when the context is done are ignored and the context error is returned.
+func (ro *runOptions) handleFiles(ctx context.Context, files []file, metadata Metadata) error {
+	rec := getRecorder(ctx)
+
+	results := make([]*result, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(ro.workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = ro.handleBuffered(ctx, rec, files[i], metadata)
+			}
+		}()
+	}
+
+feed:
+	for i := range files {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	errs := make([]error, 0, len(files)+1)
+	for _, result := range results {
+		if result == nil {
+			continue // not handled because of context cancellation
+		}
+		errs = append(errs, result.flush(GetLogger(ctx), rec))
+	}
+	errs = append(errs, ctx.Err())
+	return errors.Join(errs...)
+}
+
+// result is the buffered outcome of a file handling.
+type result struct {
+	err  error
+	logs *bufferedLogger
+	rec  *recorder
+}
+
+// handleBuffered handles the input file with a buffered logger and recorder.
+func (ro *runOptions) handleBuffered(ctx context.Context, parent *recorder, f file, metadata Metadata) *result {
+	res := &result{
+		logs: &bufferedLogger{},
+		rec:  &recorder{buffered: true, destdir: parent.destdir, dryRun: parent.dryRun, out: parent.out},
+	}
+	if res.err = ctx.Err(); res.err != nil {
+		return res
+	}
+
+	ctx = context.WithValue(ctx, loggerKey, res.logs)
+	ctx = context.WithValue(ctx, recorderKey, res.rec)
+	res.err = ro.handleFile(ctx, f, metadata)
+	return res
+}
+
+// flush writes buffered logs into log and records buffered changes into rec.
+func (r *result) flush(log clog.Logger, rec *recorder) error {
+	for _, entry := range r.logs.entries {
+		entry(log)
+	}
+
+	errs := make([]error, 0, len(r.rec.staged)+1)
+	errs = append(errs, r.err)
+	for _, entry := range r.rec.staged {
+		errs = append(errs, rec.record(entry.change, entry.apply))
+	}
+	return errors.Join(errs...)
+}
+
+// bufferedLogger is a clog.Logger keeping all its calls to replay them later on another logger.
+type bufferedLogger struct {
+	entries []func(log clog.Logger)
+}
+
+var _ clog.Logger = (*bufferedLogger)(nil) // ensure interface is implemented
+
+func (b *bufferedLogger) Debugf(msg string, args ...any) {
+	b.entries = append(b.entries, func(log clog.Logger) { log.Debugf(msg, args...) })
+}
+
+func (b *bufferedLogger) Errorf(msg string, args ...any) {
+	b.entries = append(b.entries, func(log clog.Logger) { log.Errorf(msg, args...) })
+}
+
+func (b *bufferedLogger) Infof(msg string, args ...any) {
+	b.entries = append(b.entries, func(log clog.Logger) { log.Infof(msg, args...) })
+}
+
+func (b *bufferedLogger) Warnf(msg string, args ...any) {
+	b.entries = append(b.entries, func(log clog.Logger) { log.Warnf(msg, args...) })
+}
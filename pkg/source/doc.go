@@ -0,0 +1,40 @@
+/*
+The source package provides functions to retrieve external templates sources (git repositories or tarballs)
+configured in craft.Configuration Templates property.
+
+The main function to be used is Fetch and it can be tuned with options (see documentation).
+Fetched templates are cached in the user cache directory and can be given to generate.Run with generate.WithTemplates.
+
+Example:
+
+	func main() {
+		ctx := context.Background()
+		destdir, _ := os.Getwd()
+
+		var config craft.Configuration
+		if err := craft.Read(destdir, &config); err != nil {
+			// handle err
+		}
+
+		dir, err := source.Fetch(ctx, *config.Templates)
+		if err != nil {
+			// handle err
+		}
+
+		config, report, err := generate.Run(ctx, config,
+			generate.WithDestination(destdir),
+			generate.WithTemplates(".", generate.TemplateLayer(dir, cfs.OS())))
+	}
+
+Example only using the cache (no network access):
+
+	func main() {
+		ctx := context.Background()
+
+		dir, err := source.Fetch(ctx, craft.Templates{Source: "https://github.com/org/templates.git", Ref: "v1.0.0"}, source.WithOffline())
+		if errors.Is(err, source.ErrNotCached) {
+			// handle not cached source
+		}
+	}
+*/
+package source
@@ -0,0 +1,351 @@
+package source
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/kilianpaquier/cli-sdk/pkg/clog"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+)
+
+var (
+	// ErrChecksumMismatch is the error returned (wrapped) when a fetched source content doesn't match its pinned checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrNotCached is the error returned (wrapped) when Fetch is called with WithOffline and the source was never fetched before.
+	ErrNotCached = errors.New("source not cached")
+)
+
+// FetchOption represents an option to be given to Fetch function.
+type FetchOption func(fetchOptions) fetchOptions
+
+// WithCacheDir sets the directory where sources are fetched.
+//
+// By default, it's craft/templates in os.UserCacheDir.
+func WithCacheDir(dir string) FetchOption {
+	return func(o fetchOptions) fetchOptions {
+		o.cacheDir = dir
+		return o
+	}
+}
+
+// WithHTTPClient sets the http client used to download tarballs.
+//
+// By default, it's cleanhttp.DefaultClient.
+func WithHTTPClient(client *http.Client) FetchOption {
+	return func(o fetchOptions) fetchOptions {
+		o.client = client
+		return o
+	}
+}
+
+// WithLogger sets the logger used during fetching.
+func WithLogger(log clog.Logger) FetchOption {
+	return func(o fetchOptions) fetchOptions {
+		o.logger = log
+		return o
+	}
+}
+
+// WithOffline makes Fetch only use the cache, without any network access.
+//
+// ErrNotCached is returned when the source was never fetched before.
+func WithOffline() FetchOption {
+	return func(o fetchOptions) fetchOptions {
+		o.offline = true
+		return o
+	}
+}
+
+// fetchOptions represents the struct with all available options in Fetch function.
+type fetchOptions struct {
+	cacheDir string
+	client   *http.Client
+	logger   clog.Logger
+	offline  bool
+}
+
+// newOpt creates a new option struct with all input Option functions while taking care of default values.
+func newOpt(opts ...FetchOption) (fetchOptions, error) {
+	var o fetchOptions
+	for _, opt := range opts {
+		if opt != nil {
+			o = opt(o)
+		}
+	}
+
+	if o.cacheDir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return o, fmt.Errorf("user cache dir: %w", err)
+		}
+		o.cacheDir = filepath.Join(cache, "craft", "templates")
+	}
+	if o.client == nil {
+		o.client = cleanhttp.DefaultClient()
+	}
+	if o.logger == nil {
+		o.logger = clog.Noop()
+	}
+	return o, nil
+}
+
+// Fetch retrieves the input templates source into the cache directory and returns the path to its templates directory
+// (source Dir inside the fetched content).
+//
+// The source is fetched again at every call (to follow branches updates for instance),
+// except with WithOffline where only the cache is used.
+// When a checksum is pinned, the source content is verified against it before being used (and before replacing the cache),
+// otherwise the computed checksum is logged to be pinned.
+func Fetch(ctx context.Context, templates craft.Templates, opts ...FetchOption) (string, error) {
+	o, err := newOpt(opts...)
+	if err != nil {
+		return "", err
+	}
+
+	if templates.Dir != "" && !filepath.IsLocal(templates.Dir) {
+		return "", fmt.Errorf("invalid templates dir '%s': must be a relative path inside the source", templates.Dir)
+	}
+	// avoid source or ref being read as git options (e.g. --upload-pack=...)
+	if strings.HasPrefix(templates.Source, "-") {
+		return "", fmt.Errorf("invalid templates source '%s': must not start with '-'", templates.Source)
+	}
+	if strings.HasPrefix(templates.Ref, "-") {
+		return "", fmt.Errorf("invalid templates ref '%s': must not start with '-'", templates.Ref)
+	}
+	src := templates.Source
+	if _, err := os.Stat(src); err == nil {
+		src, _ = filepath.Abs(src) // local paths are cached independently of the current directory
+	}
+	dir := filepath.Join(o.cacheDir, cacheKey(src, templates.Ref))
+
+	if o.offline {
+		if !cfs.Exists(dir) {
+			return "", fmt.Errorf("'%s': %w, fetch it at least once without offline mode", templates.Source, ErrNotCached)
+		}
+		if err := verify(dir, templates.Checksum); err != nil {
+			return "", fmt.Errorf("cached '%s': %w", templates.Source, err)
+		}
+		o.logger.Debugf("using cached templates source '%s'", templates.Source)
+		return filepath.Join(dir, templates.Dir), nil
+	}
+
+	if err := os.MkdirAll(o.cacheDir, cfs.RwxRxRxRx); err != nil {
+		return "", fmt.Errorf("create cache dir: %w", err)
+	}
+	tmp, err := os.MkdirTemp(o.cacheDir, filepath.Base(dir)+"-*")
+	if err != nil {
+		return "", fmt.Errorf("create temporary dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	o.logger.Infof("fetching templates source '%s'", templates.Source)
+	if isTarball(src) {
+		err = o.fetchTarball(ctx, src, tmp)
+	} else {
+		err = fetchGit(ctx, src, templates.Ref, tmp)
+	}
+	if err != nil {
+		return "", fmt.Errorf("fetch '%s': %w", templates.Source, err)
+	}
+
+	if err := verify(tmp, templates.Checksum); err != nil {
+		return "", fmt.Errorf("fetched '%s': %w", templates.Source, err)
+	}
+	if templates.Checksum == "" {
+		sum, _ := checksum(tmp) // already computed without error in verify
+		o.logger.Infof("templates source '%s' fetched with checksum '%s', it can be pinned with templates checksum in %s", templates.Source, sum, craft.File)
+	}
+
+	// replace previous cache
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("remove previous cache: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", fmt.Errorf("save cache: %w", err)
+	}
+	return filepath.Join(dir, templates.Dir), nil
+}
+
+// cacheKey returns the cache directory name of src at ref.
+func cacheKey(src, ref string) string {
+	sum := sha256.Sum256([]byte(src + "@" + ref))
+	return hex.EncodeToString(sum[:8])
+}
+
+// isTarball returns whether src is a tarball path or URL.
+func isTarball(src string) bool {
+	return strings.HasSuffix(src, ".tar") || strings.HasSuffix(src, ".tar.gz") || strings.HasSuffix(src, ".tgz")
+}
+
+// fetchGit clones src git repository into dest and checks out ref (or default branch if empty).
+//
+// The .git directory is removed afterwards since only the files tree is of interest.
+func fetchGit(ctx context.Context, src, ref, dest string) error {
+	if err := git(ctx, "", "clone", "--quiet", "--", src, dest); err != nil {
+		return err
+	}
+	if ref != "" {
+		if err := git(ctx, dest, "checkout", "--quiet", ref); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(filepath.Join(dest, ".git")) //nolint:wrapcheck
+}
+
+// git runs git command with args in dir.
+func git(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s with response '%s': %w", args[0], strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// fetchTarball extracts src tarball (local path or HTTP(S) URL, gzipped or not) into dest.
+func (o fetchOptions) fetchTarball(ctx context.Context, src, dest string) error {
+	var reader io.ReadCloser
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+		if err != nil {
+			return fmt.Errorf("create request: %w", err)
+		}
+		resp, err := o.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("download: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("download: unexpected status '%s'", resp.Status)
+		}
+		reader = resp.Body
+	} else {
+		file, err := os.Open(src)
+		if err != nil {
+			return fmt.Errorf("open: %w", err)
+		}
+		reader = file
+	}
+	defer reader.Close()
+
+	// tarball may be gzipped whatever its extension
+	buffered := bufio.NewReader(reader)
+	var archive io.Reader = buffered
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("gzip: %w", err)
+		}
+		defer gz.Close()
+		archive = gz
+	}
+	return o.extract(tar.NewReader(archive), dest)
+}
+
+// extract writes all directories and regular files from tr into dest.
+func (o fetchOptions) extract(tr *tar.Reader, dest string) error {
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tarball: %w", err)
+		}
+
+		name := filepath.FromSlash(strings.TrimPrefix(header.Name, "./"))
+		if name == "" || name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid tarball entry '%s'", header.Name)
+		}
+		target := filepath.Join(dest, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, cfs.RwxRxRxRx); err != nil {
+				return fmt.Errorf("create '%s': %w", header.Name, err)
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target, header.FileInfo().Mode().Perm()); err != nil {
+				return fmt.Errorf("extract '%s': %w", header.Name, err)
+			}
+		default:
+			o.logger.Warnf("ignoring tarball entry '%s' since it's neither a directory nor a regular file", header.Name)
+		}
+	}
+}
+
+// extractFile writes reader content into target with perm.
+func extractFile(reader io.Reader, target string, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), cfs.RwxRxRxRx); err != nil {
+		return err //nolint:wrapcheck
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm|0o600)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if _, err := io.Copy(file, reader); err != nil { //nolint:gosec
+		file.Close()
+		return err //nolint:wrapcheck
+	}
+	return file.Close() //nolint:wrapcheck
+}
+
+// verify returns ErrChecksumMismatch (wrapped) in case expected isn't empty and dir checksum is different.
+func verify(dir, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	actual, err := checksum(dir)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("%w: expected '%s' but got '%s'", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
+
+// checksum returns the checksum of dir files tree, computed from all regular files paths and contents.
+//
+// Its format is the same as generate.Checksum.
+func checksum(dir string) (string, error) {
+	var sums bytes.Buffer
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Fprintf(&sums, "%s %s\n", generate.Checksum(content), filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("compute checksum: %w", err)
+	}
+	return generate.Checksum(sums.Bytes()), nil
+}
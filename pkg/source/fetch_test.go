@@ -0,0 +1,245 @@
+package source_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/kilianpaquier/cli-sdk/pkg/cfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kilianpaquier/craft/pkg/craft"
+	"github.com/kilianpaquier/craft/pkg/generate"
+	"github.com/kilianpaquier/craft/pkg/source"
+)
+
+func TestFetch_Git(t *testing.T) {
+	ctx := context.Background()
+
+	// Arrange
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=craft", "-c", "user.email=craft@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	git("init", "--quiet")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "templates"), cfs.RwxRxRxRx))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "templates", "README.md.tmpl"), []byte("v1"), cfs.RwRR))
+	git("add", "--all")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1.0.0")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "templates", "README.md.tmpl"), []byte("v2"), cfs.RwRR))
+	git("commit", "--quiet", "--all", "-m", "v2")
+
+	t.Run("success_ref", func(t *testing.T) {
+		// Act
+		dir, err := source.Fetch(ctx, craft.Templates{Dir: "templates", Ref: "v1.0.0", Source: repo}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "README.md.tmpl"))
+		assert.NoDirExists(t, filepath.Join(dir, "..", ".git"))
+		content, err := os.ReadFile(filepath.Join(dir, "README.md.tmpl"))
+		require.NoError(t, err)
+		assert.Equal(t, "v1", string(content))
+	})
+
+	t.Run("success_default_branch", func(t *testing.T) {
+		// Act
+		dir, err := source.Fetch(ctx, craft.Templates{Dir: "templates", Source: repo}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(dir, "README.md.tmpl"))
+		require.NoError(t, err)
+		assert.Equal(t, "v2", string(content))
+	})
+
+	t.Run("error_unknown_ref", func(t *testing.T) {
+		// Act
+		_, err := source.Fetch(ctx, craft.Templates{Ref: "v0.0.0", Source: repo}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		assert.ErrorContains(t, err, "git checkout")
+	})
+}
+
+func TestFetch_Tarball(t *testing.T) {
+	ctx := context.Background()
+
+	// Arrange
+	tarball := newTarball(t, map[string]string{"templates/README.md.tmpl": "content"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/templates.tar.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(tarball)
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "templates.tgz")
+	require.NoError(t, os.WriteFile(path, tarball, cfs.RwRR))
+
+	t.Run("success_url", func(t *testing.T) {
+		// Act
+		dir, err := source.Fetch(ctx, craft.Templates{Dir: "templates", Source: server.URL + "/templates.tar.gz"},
+			source.WithCacheDir(t.TempDir()), source.WithHTTPClient(server.Client()))
+
+		// Assert
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(dir, "README.md.tmpl"))
+		require.NoError(t, err)
+		assert.Equal(t, "content", string(content))
+	})
+
+	t.Run("success_path", func(t *testing.T) {
+		// Act
+		dir, err := source.Fetch(ctx, craft.Templates{Dir: "templates", Source: path}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "README.md.tmpl"))
+	})
+
+	t.Run("error_status", func(t *testing.T) {
+		// Act
+		_, err := source.Fetch(ctx, craft.Templates{Source: server.URL + "/unknown.tar.gz"},
+			source.WithCacheDir(t.TempDir()), source.WithHTTPClient(server.Client()))
+
+		// Assert
+		assert.ErrorContains(t, err, "unexpected status '404 Not Found'")
+	})
+
+	t.Run("error_traversal", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "templates.tar.gz")
+		require.NoError(t, os.WriteFile(path, newTarball(t, map[string]string{"../README.md.tmpl": "content"}), cfs.RwRR))
+
+		// Act
+		_, err := source.Fetch(ctx, craft.Templates{Source: path}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid tarball entry '../README.md.tmpl'")
+	})
+}
+
+func TestFetch_Checksum(t *testing.T) {
+	ctx := context.Background()
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "templates.tar.gz")
+	require.NoError(t, os.WriteFile(path, newTarball(t, map[string]string{"README.md.tmpl": "content"}), cfs.RwRR))
+
+	t.Run("error_mismatch", func(t *testing.T) {
+		// Arrange
+		cachedir := t.TempDir()
+
+		// Act
+		_, err := source.Fetch(ctx, craft.Templates{Checksum: generate.Checksum([]byte("other")), Source: path}, source.WithCacheDir(cachedir))
+
+		// Assert
+		assert.ErrorIs(t, err, source.ErrChecksumMismatch)
+		entries, err := os.ReadDir(cachedir)
+		require.NoError(t, err)
+		assert.Empty(t, entries) // cache isn't populated with unverified content
+	})
+
+	t.Run("success_pinned", func(t *testing.T) {
+		// Arrange
+		expected := generate.Checksum([]byte(generate.Checksum([]byte("content")) + " README.md.tmpl\n"))
+
+		// Act
+		dir, err := source.Fetch(ctx, craft.Templates{Checksum: expected, Source: path}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "README.md.tmpl"))
+	})
+}
+
+func TestFetch_Offline(t *testing.T) {
+	ctx := context.Background()
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "templates.tar.gz")
+	require.NoError(t, os.WriteFile(path, newTarball(t, map[string]string{"README.md.tmpl": "content"}), cfs.RwRR))
+	templates := craft.Templates{Source: path}
+
+	t.Run("error_not_cached", func(t *testing.T) {
+		// Act
+		_, err := source.Fetch(ctx, templates, source.WithCacheDir(t.TempDir()), source.WithOffline())
+
+		// Assert
+		assert.ErrorIs(t, err, source.ErrNotCached)
+	})
+
+	t.Run("success_cached", func(t *testing.T) {
+		// Arrange
+		cachedir := t.TempDir()
+		expected, err := source.Fetch(ctx, templates, source.WithCacheDir(cachedir))
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(path)) // source isn't available anymore
+
+		// Act
+		dir, err := source.Fetch(ctx, templates, source.WithCacheDir(cachedir), source.WithOffline())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, expected, dir)
+		assert.FileExists(t, filepath.Join(dir, "README.md.tmpl"))
+	})
+}
+
+func TestFetch_InvalidDir(t *testing.T) {
+	// Act
+	_, err := source.Fetch(context.Background(), craft.Templates{Dir: "../templates", Source: "templates.tar"}, source.WithCacheDir(t.TempDir()))
+
+	// Assert
+	assert.ErrorContains(t, err, "invalid templates dir '../templates'")
+}
+
+func TestFetch_InvalidSource(t *testing.T) {
+	t.Run("error_source_option", func(t *testing.T) {
+		// Act
+		_, err := source.Fetch(context.Background(), craft.Templates{Source: "--upload-pack=touch pwned"}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid templates source '--upload-pack=touch pwned'")
+	})
+
+	t.Run("error_ref_option", func(t *testing.T) {
+		// Act
+		_, err := source.Fetch(context.Background(), craft.Templates{Ref: "--orphan", Source: "https://example.com/templates.git"}, source.WithCacheDir(t.TempDir()))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid templates ref '--orphan'")
+	})
+}
+
+// newTarball returns a gzipped tarball with input files.
+func newTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: int64(cfs.RwRR), Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
@@ -1,7 +1,8 @@
 /*
 Package templating is a small wrapper of template.Template.
 
-It provides a new function Execute which ensures the target templated file sees its rights reevaluated depending on its extension.
+It provides a new function Execute which ensures the target templated file sees its rights reevaluated depending on its extension
+and that its content is valid according to its extension (YAML, JSON, JSON5 or TOML, see Validate).
 
 It also provides some functions to give to template.Template FuncMap option.
 */
@@ -3,6 +3,7 @@ package templating
 import (
 	"bytes"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
@@ -14,6 +15,7 @@ import (
 // Execute runs tmpl.Execute with input data and write result into given dest file.
 //
 // When Execute is called, it deletes dest in case it already exists and reevaluate its rights (specific to linux).
+// The result isn't written in case it isn't valid according to dest extension (see Validate).
 func Execute(tmpl *template.Template, data any, dest string) error {
 	// create destination directory only if one file would be generated
 	if err := os.MkdirAll(filepath.Dir(dest), cfs.RwxRxRxRx); err != nil && !os.IsExist(err) {
@@ -24,19 +26,28 @@ func Execute(tmpl *template.Template, data any, dest string) error {
 	if err := tmpl.Execute(&result, data); err != nil {
 		return fmt.Errorf("template execution: %w", err)
 	}
+	if err := Validate(dest, result.Bytes()); err != nil {
+		return err
+	}
 
 	if err := os.WriteFile(dest, result.Bytes(), cfs.RwRR); err != nil {
 		return fmt.Errorf("write file: %w", err)
 	}
 
-	mode := cfs.RwRR
-	if slices.Contains([]string{".sh"}, filepath.Ext(dest)) {
-		mode = cfs.RwxRxRxRx
-	}
 	// force refresh rights since WriteFile doesn't do it
 	// in case the target file already exists
-	if err := os.Chmod(dest, mode); err != nil {
+	if err := os.Chmod(dest, Mode(dest)); err != nil {
 		return fmt.Errorf("chmod: %w", err)
 	}
 	return nil
 }
+
+// Mode returns the file mode a templated file should have depending on its extension (specific to linux).
+//
+// Shell scripts are executable while all other files are only readable and writable.
+func Mode(dest string) fs.FileMode {
+	if slices.Contains([]string{".sh"}, filepath.Ext(dest)) {
+		return cfs.RwxRxRxRx
+	}
+	return cfs.RwRR
+}
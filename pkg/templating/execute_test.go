@@ -107,3 +107,21 @@ func TestExecute(t *testing.T) {
 		assert.Equal(t, "hey ! A name", string(bytes))
 	})
 }
+
+func TestMode(t *testing.T) {
+	t.Run("success_executable", func(t *testing.T) {
+		// Act
+		mode := templating.Mode(filepath.Join("scripts", "launcher.sh"))
+
+		// Assert
+		assert.Equal(t, cfs.RwxRxRxRx, mode)
+	})
+
+	t.Run("success_default", func(t *testing.T) {
+		// Act
+		mode := templating.Mode(filepath.Join(".github", "workflows", "ci.yml"))
+
+		// Assert
+		assert.Equal(t, cfs.RwRR, mode)
+	})
+}
@@ -0,0 +1,354 @@
+package templating
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// json5Error is a JSON5 syntax error with the offset of the invalid character.
+type json5Error struct {
+	msg    string
+	offset int
+}
+
+func (e *json5Error) Error() string {
+	return e.msg
+}
+
+// json5Parser is a JSON5 (https://spec.json5.org) syntax validator.
+//
+// It's used instead of a decoder since JSON5 decoders (ports of encoding/json) are usually stricter than the specification
+// (e.g. with escaped characters in strings) while JSON5 files are mostly read by JavaScript tools following it (e.g. renovate).
+type json5Parser struct {
+	content []byte
+	pos     int
+}
+
+// validateJSON5 returns a json5Error in case content isn't a valid JSON5 document.
+func validateJSON5(content []byte) error {
+	p := &json5Parser{content: content}
+	if err := p.skip(); err != nil {
+		return err
+	}
+	if err := p.value(); err != nil {
+		return err
+	}
+	if err := p.skip(); err != nil {
+		return err
+	}
+	if p.pos < len(p.content) {
+		return p.errorf("invalid character %q after top-level value", p.peek())
+	}
+	return nil
+}
+
+func (p *json5Parser) errorf(format string, args ...any) error {
+	return &json5Error{msg: fmt.Sprintf(format, args...), offset: p.pos}
+}
+
+// peek returns the current rune (utf8.RuneError at the end of content).
+func (p *json5Parser) peek() rune {
+	r, _ := utf8.DecodeRune(p.content[p.pos:])
+	return r
+}
+
+// next moves to the next rune.
+func (p *json5Parser) next() {
+	_, size := utf8.DecodeRune(p.content[p.pos:])
+	p.pos += size
+}
+
+// skip skips whitespaces and comments.
+func (p *json5Parser) skip() error {
+	for p.pos < len(p.content) {
+		rest := p.content[p.pos:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("//")):
+			end := bytes.IndexAny(rest, "\n\r")
+			if end < 0 {
+				end = len(rest)
+			}
+			p.pos += end
+		case bytes.HasPrefix(rest, []byte("/*")):
+			end := bytes.Index(rest[2:], []byte("*/"))
+			if end < 0 {
+				return p.errorf("unterminated multi-line comment")
+			}
+			p.pos += end + 4
+		case unicode.IsSpace(p.peek()) || p.peek() == '\uFEFF':
+			p.next()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// value validates any JSON5 value at current position.
+func (p *json5Parser) value() error {
+	if p.pos >= len(p.content) {
+		return p.errorf("unexpected end of input")
+	}
+	switch r := p.peek(); {
+	case r == '{':
+		return p.object()
+	case r == '[':
+		return p.array()
+	case r == '"' || r == '\'':
+		return p.string(r)
+	case r == '-' || r == '+' || r == '.' || r == 'I' || r == 'N' || ('0' <= r && r <= '9'):
+		return p.number()
+	default:
+		for _, literal := range []string{"true", "false", "null"} {
+			if bytes.HasPrefix(p.content[p.pos:], []byte(literal)) {
+				p.pos += len(literal)
+				if p.pos < len(p.content) && isIdentifierPart(p.peek()) {
+					return p.errorf("invalid character %q in literal %s", p.peek(), literal)
+				}
+				return nil
+			}
+		}
+		return p.errorf("invalid character %q looking for beginning of value", r)
+	}
+}
+
+// object validates an object at current position.
+func (p *json5Parser) object() error {
+	p.next() // {
+	for {
+		if err := p.skip(); err != nil {
+			return err
+		}
+		if p.peek() == '}' {
+			p.next()
+			return nil
+		}
+
+		// key
+		if r := p.peek(); r == '"' || r == '\'' {
+			if err := p.string(r); err != nil {
+				return err
+			}
+		} else if err := p.identifier(); err != nil {
+			return err
+		}
+		if err := p.skip(); err != nil {
+			return err
+		}
+		if p.peek() != ':' {
+			return p.errorf("invalid character %q after object key", p.peek())
+		}
+		p.next()
+
+		// value
+		if err := p.skip(); err != nil {
+			return err
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+		if err := p.skip(); err != nil {
+			return err
+		}
+		switch p.peek() {
+		case ',':
+			p.next()
+		case '}':
+			p.next()
+			return nil
+		default:
+			return p.errorf("invalid character %q after object key:value pair", p.peek())
+		}
+	}
+}
+
+// array validates an array at current position.
+func (p *json5Parser) array() error {
+	p.next() // [
+	for {
+		if err := p.skip(); err != nil {
+			return err
+		}
+		if p.peek() == ']' {
+			p.next()
+			return nil
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+		if err := p.skip(); err != nil {
+			return err
+		}
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+			p.next()
+			return nil
+		default:
+			return p.errorf("invalid character %q after array element", p.peek())
+		}
+	}
+}
+
+// string validates a string quoted with quote at current position.
+func (p *json5Parser) string(quote rune) error {
+	p.next() // opening quote
+	for {
+		if p.pos >= len(p.content) {
+			return p.errorf("unterminated string")
+		}
+		switch r := p.peek(); r {
+		case quote:
+			p.next()
+			return nil
+		case '\n', '\r':
+			return p.errorf("invalid line terminator in string")
+		case '\\':
+			p.next()
+			if err := p.escape(); err != nil {
+				return err
+			}
+		default:
+			p.next()
+		}
+	}
+}
+
+// escape validates a string escape sequence at current position (after the backslash).
+func (p *json5Parser) escape() error {
+	if p.pos >= len(p.content) {
+		return p.errorf("unterminated string")
+	}
+	switch r := p.peek(); {
+	case r == 'x':
+		p.next()
+		return p.hex(2)
+	case r == 'u':
+		p.next()
+		return p.hex(4)
+	case r == '0':
+		p.next()
+		if '0' <= p.peek() && p.peek() <= '9' {
+			return p.errorf("invalid character %q in string escape code", p.peek())
+		}
+		return nil
+	case '1' <= r && r <= '9':
+		return p.errorf("invalid character %q in string escape code", r)
+	case r == '\r':
+		p.next()
+		if p.peek() == '\n' {
+			p.next()
+		}
+		return nil
+	default: // any other character (line terminators included) represents itself
+		p.next()
+		return nil
+	}
+}
+
+// hex validates count hexadecimal digits at current position.
+func (p *json5Parser) hex(count int) error {
+	for range count {
+		if p.pos >= len(p.content) || !strings.ContainsRune("0123456789abcdefABCDEF", p.peek()) {
+			return p.errorf("invalid hexadecimal escape sequence")
+		}
+		p.next()
+	}
+	return nil
+}
+
+// identifier validates an unquoted object key at current position.
+func (p *json5Parser) identifier() error {
+	if r := p.peek(); p.pos >= len(p.content) || !isIdentifierStart(r) {
+		return p.errorf("invalid character %q looking for beginning of object key", r)
+	}
+	p.next()
+	for p.pos < len(p.content) && isIdentifierPart(p.peek()) {
+		p.next()
+	}
+	return nil
+}
+
+// number validates a number at current position.
+func (p *json5Parser) number() error {
+	if r := p.peek(); r == '-' || r == '+' {
+		p.next()
+	}
+	rest := p.content[p.pos:]
+	for _, literal := range []string{"Infinity", "NaN"} {
+		if bytes.HasPrefix(rest, []byte(literal)) {
+			p.pos += len(literal)
+			return p.numberEnd()
+		}
+	}
+
+	// hexadecimal
+	if bytes.HasPrefix(rest, []byte("0x")) || bytes.HasPrefix(rest, []byte("0X")) {
+		p.pos += 2
+		start := p.pos
+		for p.pos < len(p.content) && strings.ContainsRune("0123456789abcdefABCDEF", p.peek()) {
+			p.next()
+		}
+		if p.pos == start {
+			return p.errorf("invalid hexadecimal number")
+		}
+		return p.numberEnd()
+	}
+
+	// decimal
+	integer := p.digits()
+	if integer > 1 && rest[0] == '0' {
+		return p.errorf("invalid leading zero in number")
+	}
+	fraction := 0
+	if p.peek() == '.' {
+		p.next()
+		fraction = p.digits()
+	}
+	if integer == 0 && fraction == 0 {
+		return p.errorf("invalid number")
+	}
+	if r := p.peek(); r == 'e' || r == 'E' {
+		p.next()
+		if r := p.peek(); r == '-' || r == '+' {
+			p.next()
+		}
+		if p.digits() == 0 {
+			return p.errorf("invalid number exponent")
+		}
+	}
+	return p.numberEnd()
+}
+
+// digits moves after all decimal digits at current position and returns their count.
+func (p *json5Parser) digits() int {
+	count := 0
+	for p.pos < len(p.content) && '0' <= p.peek() && p.peek() <= '9' {
+		p.next()
+		count++
+	}
+	return count
+}
+
+// numberEnd ensures a number isn't directly followed by an identifier character.
+func (p *json5Parser) numberEnd() error {
+	if p.pos < len(p.content) && isIdentifierPart(p.peek()) {
+		return p.errorf("invalid character %q in number", p.peek())
+	}
+	return nil
+}
+
+// isIdentifierStart returns truthy in case r can start an ECMAScript identifier.
+func isIdentifierStart(r rune) bool {
+	return r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r)
+}
+
+// isIdentifierPart returns truthy in case r can be part of an ECMAScript identifier.
+func isIdentifierPart(r rune) bool {
+	return isIdentifierStart(r) || unicode.IsDigit(r) ||
+		unicode.In(r, unicode.Mn, unicode.Mc, unicode.Pc) || r == '\u200C' || r == '\u200D'
+}
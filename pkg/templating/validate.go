@@ -0,0 +1,95 @@
+package templating
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidOutput is the error returned (wrapped) by Validate when a rendered file isn't valid
+// according to its format (deduced from its extension).
+var ErrInvalidOutput = errors.New("invalid output")
+
+// yamlLine matches the line number in yaml.v3 errors messages (e.g. "yaml: line 5: did not find expected key").
+var yamlLine = regexp.MustCompile(`line (\d+): `)
+
+// Validate parses content according to dest extension and returns an error pointing to the invalid line of content in case it's invalid.
+//
+// Supported formats are YAML (.yml and .yaml), JSON (.json), JSON5 (.json5) and TOML (.toml),
+// files with any other extension are always valid.
+func Validate(dest string, content []byte) error {
+	var format string
+	var err error
+	var line int
+	switch strings.ToLower(filepath.Ext(dest)) {
+	case ".yml", ".yaml":
+		format = "YAML"
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		for err == nil {
+			var node yaml.Node
+			err = decoder.Decode(&node)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if matches := yamlLine.FindStringSubmatch(err.Error()); matches != nil {
+			line, _ = strconv.Atoi(matches[1])
+			err = errors.New(strings.TrimPrefix(strings.Replace(err.Error(), matches[0], "", 1), "yaml: "))
+		}
+	case ".json":
+		format = "JSON"
+		var value any
+		if err = json.Unmarshal(content, &value); err == nil {
+			return nil
+		}
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			line = lineAt(content, syntax.Offset)
+		}
+	case ".json5":
+		format = "JSON5"
+		if err = validateJSON5(content); err == nil {
+			return nil
+		}
+		var syntax *json5Error
+		if errors.As(err, &syntax) {
+			line = lineAt(content, int64(syntax.offset)+1)
+		}
+	case ".toml":
+		format = "TOML"
+		var value map[string]any
+		if err = toml.Unmarshal(content, &value); err == nil {
+			return nil
+		}
+		var decode *toml.DecodeError
+		if errors.As(err, &decode) {
+			line, _ = decode.Position()
+		}
+	default:
+		return nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if line < 1 || line > len(lines) {
+		return fmt.Errorf("%w, not a valid %s file: %w", ErrInvalidOutput, format, err)
+	}
+	return fmt.Errorf("%w, not a valid %s file: line %d (%s): %w", ErrInvalidOutput, format, line, strings.TrimSpace(lines[line-1]), err)
+}
+
+// lineAt returns the line number (starting at 1) of offset in content.
+func lineAt(content []byte, offset int64) int {
+	offset = min(offset, int64(len(content)))
+	if offset > 0 {
+		offset-- // offset is the number of bytes read, the invalid one included
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
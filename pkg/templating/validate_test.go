@@ -0,0 +1,132 @@
+package templating_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kilianpaquier/craft/pkg/templating"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("success_unknown_extension", func(t *testing.T) {
+		// Act
+		err := templating.Validate("file.txt", []byte("{ invalid: ["))
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("success_yaml_documents", func(t *testing.T) {
+		// Arrange
+		content := []byte("key: value\n---\nlist:\n  - item\n")
+
+		// Act
+		err := templating.Validate("file.yml", content)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("error_yaml", func(t *testing.T) {
+		// Arrange
+		content := []byte("key: value\nlist:\n  - item\n - invalid\n")
+
+		// Act
+		err := templating.Validate("file.yaml", content)
+
+		// Assert
+		assert.ErrorIs(t, err, templating.ErrInvalidOutput)
+		assert.ErrorContains(t, err, "not a valid YAML file: line 3 (- item): did not find expected key")
+	})
+
+	t.Run("success_json", func(t *testing.T) {
+		// Arrange
+		content := []byte(`{ "key": ["value", 1, true, null] }`)
+
+		// Act
+		err := templating.Validate("file.json", content)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("error_json", func(t *testing.T) {
+		// Arrange
+		content := []byte("{\n  \"key\": \"value\",\n}\n")
+
+		// Act
+		err := templating.Validate("file.json", content)
+
+		// Assert
+		assert.ErrorIs(t, err, templating.ErrInvalidOutput)
+		assert.ErrorContains(t, err, "not a valid JSON file: line 3 (})")
+	})
+
+	t.Run("success_json5", func(t *testing.T) {
+		// Arrange
+		content := []byte(`// comment
+{
+  /* multi-line
+     comment */
+  $schema: "https://docs.renovatebot.com/renovate-schema.json",
+  matchPackageNames: ["/^github\.com\/kilianpaquier\//"],
+  'single': 'quotes',
+  numbers: [0x1F, .5, +1, -Infinity, NaN, 1e3,],
+}
+`)
+
+		// Act
+		err := templating.Validate("renovate.json5", content)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("error_json5", func(t *testing.T) {
+		// Arrange
+		content := []byte("{\n  key: 'value',\n  invalid key: true,\n}\n")
+
+		// Act
+		err := templating.Validate("file.json5", content)
+
+		// Assert
+		assert.ErrorIs(t, err, templating.ErrInvalidOutput)
+		assert.ErrorContains(t, err, "not a valid JSON5 file: line 3 (invalid key: true,)")
+	})
+
+	t.Run("error_json5_unterminated", func(t *testing.T) {
+		// Arrange
+		content := []byte("{\n  key: [1, 2\n")
+
+		// Act
+		err := templating.Validate("file.json5", content)
+
+		// Assert
+		assert.ErrorIs(t, err, templating.ErrInvalidOutput)
+		assert.ErrorContains(t, err, "not a valid JSON5 file")
+	})
+
+	t.Run("success_toml", func(t *testing.T) {
+		// Arrange
+		content := []byte("[section]\nkey = \"value\"\n")
+
+		// Act
+		err := templating.Validate("file.toml", content)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("error_toml", func(t *testing.T) {
+		// Arrange
+		content := []byte("[section]\nkey = \"value\"\ninvalid\n")
+
+		// Act
+		err := templating.Validate("file.toml", content)
+
+		// Assert
+		assert.ErrorIs(t, err, templating.ErrInvalidOutput)
+		assert.ErrorContains(t, err, "not a valid TOML file: line 3 (invalid)")
+	})
+}
@@ -1,4 +1,4 @@
-# Code generated by craft; DO NOT EDIT.
+{{/* Code generated by craft from chart/templates/_helpers.tpl.tmpl; DO NOT EDIT. */}}
 
 {{/*
 Expand the name of the chart.